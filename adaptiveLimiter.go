@@ -0,0 +1,172 @@
+package utils
+
+import (
+	"github.com/go-resty/resty/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type AdaptiveLimiterOptions struct {
+	// InitialRate is the starting rate, events per second.
+	// Default: 1
+	InitialRate float64
+	// MinRate and MaxRate bound the rate.
+	// Default: 0.1, 100
+	MinRate, MaxRate float64
+	// Increase is added to the rate after every success.
+	// Default: 0.1
+	Increase float64
+	// Decrease multiplies the rate after an overload.
+	// Default: 0.5
+	Decrease float64
+	// DecreaseCooldown is the minimal time between two decreases, so the
+	// overloads reported by the requests sent together count as one.
+	// Default: 1s
+	DecreaseCooldown time.Duration
+	// Burst is the burst of the underlying token bucket.
+	// Default: 1
+	Burst int
+}
+
+// AdaptiveLimiter is a token bucket limiter whose rate follows the server
+// feedback using AIMD: the rate grows linearly on success and is cut on
+// overload (HTTP 429, 503 or Retry-After), so it converges on the server's
+// real limit.
+type AdaptiveLimiter struct {
+	limiter
+	bucket *tokenBucket
+
+	opts         AdaptiveLimiterOptions
+	rate         float64
+	lastDecrease time.Time
+}
+
+func NewAdaptiveLimiter(opts AdaptiveLimiterOptions) *AdaptiveLimiter {
+	opts.MinRate = If(opts.MinRate > 0, opts.MinRate, 0.1)
+	opts.MaxRate = If(opts.MaxRate > 0, opts.MaxRate, 100)
+	opts.InitialRate = Clamp(If(opts.InitialRate > 0, opts.InitialRate, 1), opts.MinRate, opts.MaxRate)
+	opts.Increase = If(opts.Increase > 0, opts.Increase, 0.1)
+	opts.Decrease = If(opts.Decrease > 0 && opts.Decrease < 1, opts.Decrease, 0.5)
+	opts.DecreaseCooldown = If(opts.DecreaseCooldown > 0, opts.DecreaseCooldown, time.Second)
+	opts.Burst = max(opts.Burst, 1)
+
+	a := &AdaptiveLimiter{opts: opts}
+	a.bucket = &tokenBucket{burst: opts.Burst}
+	a.core = a.bucket
+	a.setRate(opts.InitialRate)
+	return a
+}
+
+// setRate must be called with a.mu held.
+func (a *AdaptiveLimiter) setRate(rate float64) {
+	a.rate = Clamp(rate, a.opts.MinRate, a.opts.MaxRate)
+	a.bucket.every = time.Duration(float64(time.Second) / a.rate)
+}
+
+// Rate returns the current rate, events per second.
+func (a *AdaptiveLimiter) Rate() float64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.rate
+}
+
+// Success increases the rate additively.
+func (a *AdaptiveLimiter) Success() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.setRate(a.rate + a.opts.Increase)
+}
+
+// Overload decreases the rate multiplicatively and throttles the limiter for
+// retryAfter, if it is positive.
+func (a *AdaptiveLimiter) Overload(retryAfter time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(a.lastDecrease) >= a.opts.DecreaseCooldown {
+		a.lastDecrease = now
+		a.setRate(a.rate * a.opts.Decrease)
+	}
+	if retryAfter > 0 {
+		a.core.throttle(now.Add(retryAfter))
+	}
+}
+
+// Feedback feeds a response into the limiter: 429, 503 and any response having
+// Retry-After are overloads, the other 1xx-4xx responses are successes. The
+// remaining 5xx responses do not change the rate.
+func (a *AdaptiveLimiter) Feedback(statusCode int, header http.Header) {
+	retryAfter := ParseRetryAfter(header.Get("Retry-After"), time.Now())
+	switch {
+	case statusCode == http.StatusTooManyRequests || statusCode == http.StatusServiceUnavailable || retryAfter > 0:
+		a.Overload(retryAfter)
+	case statusCode < 500:
+		a.Success()
+	}
+}
+
+// ParseRetryAfter parses the Retry-After header value: either seconds or an
+// HTTP date. It returns 0 if the value is empty or malformed.
+func ParseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, e := strconv.ParseInt(value, 10, 64); e == nil {
+		return time.Duration(max(seconds, 0)) * time.Second
+	}
+	if t, e := http.ParseTime(value); e == nil {
+		return max(t.Sub(now), 0)
+	}
+	return 0
+}
+
+/*
+	Resty middleware
+*/
+
+// RestyUseAdaptiveLimiter makes every request of ses (retries included) wait
+// for l, and feeds the responses into l.
+func RestyUseAdaptiveLimiter(ses *resty.Client, l *AdaptiveLimiter) *resty.Client {
+	return ses.OnBeforeRequest(func(client *resty.Client, request *resty.Request) error {
+		return l.Wait(request.Context())
+	}).OnAfterResponse(func(client *resty.Client, response *resty.Response) error {
+		l.Feedback(response.StatusCode(), response.Header())
+		return nil
+	})
+}
+
+// RestyUseAdaptiveLimiterPerHost is like RestyUseAdaptiveLimiter with a limiter
+// per request host. k.NewLimiter has to return *AdaptiveLimiter for the
+// feedback to be used.
+func RestyUseAdaptiveLimiterPerHost(ses *resty.Client, k *KeyedLimiter[string]) *resty.Client {
+	return ses.OnBeforeRequest(func(client *resty.Client, request *resty.Request) error {
+		return k.Wait(request.Context(), restyRequestHost(client, request))
+	}).OnAfterResponse(func(client *resty.Client, response *resty.Response) error {
+		if l, ok := k.Get(restyRequestHost(client, response.Request)).(*AdaptiveLimiter); ok {
+			l.Feedback(response.StatusCode(), response.Header())
+		}
+		return nil
+	})
+}
+
+// restyRequestHost returns the host of the request, which URL may be relative to
+// the client's base URL.
+func restyRequestHost(client *resty.Client, request *resty.Request) string {
+	if request.RawRequest != nil {
+		return request.RawRequest.URL.Host
+	}
+	if u, e := url.Parse(request.URL); e == nil && u.Host != "" {
+		return u.Host
+	}
+	if u, e := url.Parse(client.BaseURL); e == nil {
+		return u.Host
+	}
+	return ""
+}
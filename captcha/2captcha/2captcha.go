@@ -5,7 +5,10 @@ import (
 	"encoding/json"
 	"github.com/go-resty/resty/v2"
 	"github.com/k773/utils"
+	"github.com/k773/utils/captcha/poller"
+	"github.com/k773/utils/captcha/types"
 	"github.com/k773/utils/fixedPoint"
+	"sync"
 	"time"
 )
 
@@ -28,6 +31,15 @@ type Client struct {
 	// 0 = duration is not limited.
 	// Default: 0
 	MaxWaitDuration time.Duration
+	// Poller overrides PollInterval and MaxWaitDuration if set.
+	// Default: nil
+	Poller *poller.Poller
+
+	// history is the History of the default poller used if Poller is nil; it is kept between the solves.
+	history     *poller.History
+	historyOnce sync.Once
+
+	batch batcher
 }
 
 func New(key string) *Client {
@@ -60,52 +72,71 @@ func (c *Client) SolveCaptcha(ctx context.Context, request requestInterface) (re
 	response.Response, e = c.Execute(ctx, request, endpointIn)
 	if e == nil {
 		response.Id = response.Request
-		response.Response, e = c.Wait(ctx, response.Id)
+		response.Response, e = c.wait(ctx, request.taskType(), response.Id)
+	}
+	return
+}
+
+// SolveImage solves the image captcha; the answer is validated against the first of opts, if any.
+func (c *Client) SolveImage(ctx context.Context, img []byte, opts ...types.ImageOptions) (response CaptchaResponse, e error) {
+	var o types.ImageOptions
+	if len(opts) != 0 {
+		o = opts[0]
+	}
+
+	var req = new(ImageRequest)
+	req.SetOptions(img, o)
+	if response, e = c.SolveCaptcha(ctx, req); e == nil {
+		e = o.Validate(response.Result())
 	}
 	return
 }
 
 func (c *Client) Wait(ctx context.Context, id string) (response Response, e error) {
-	var startedAt = time.Now()
-	var getTimeoutError = func() error {
-		var e = &TimeoutError{
-			TimeSpent:   time.Now().Sub(startedAt),
-			TimeAllowed: c.MaxWaitDuration,
-		}
-		if c.MaxWaitDuration != 0 && e.TimeSpent > c.MaxWaitDuration {
-			return e
+	return c.wait(ctx, "", id)
+}
+
+func (c *Client) wait(ctx context.Context, taskType, id string) (response Response, e error) {
+	e = c.poller().Poll(ctx, taskType, func(ctx context.Context) (done bool, e error) {
+		var req = &ActionRequest{Id: id, Action: "get2"}
+		if response, e = c.Execute(ctx, req, endpointRes); e == nil {
+			done = response.Request != statusNotReady
 		}
-		return nil
+		return
+	})
+	return
+}
+
+// poller returns Poller or, if it is nil, a default one using the current PollInterval and MaxWaitDuration.
+func (c *Client) poller() *poller.Poller {
+	if c.Poller != nil {
+		return c.Poller
 	}
-	for e == nil {
-		if e = ctx.Err(); e != nil {
-			continue
-		}
-		if e = getTimeoutError(); e != nil {
-			continue
-		}
+	c.historyOnce.Do(func() {
+		c.history = poller.NewHistory()
+	})
+	return poller.New(poller.Options{
+		InitialDelay: c.PollInterval,
+		Interval:     c.PollInterval,
+		MaxWait:      c.MaxWaitDuration,
+		History:      c.history,
+	})
+}
 
-		if e = utils.SleepWithContext(ctx, c.PollInterval); e == nil {
-			var req = &ActionRequest{Id: id, Action: "get2"}
-			if response, e = c.Execute(ctx, req, endpointRes); e == nil {
-				if response.Request != "CAPCHA_NOT_READY" {
-					break
-				}
-			}
-		}
+func (c *Client) Execute(ctx context.Context, request requestInterface, endpoint string) (response Response, e error) {
+	if response, e = c.execute(ctx, request, endpoint); e == nil {
+		e = response.GetError()
 	}
 	return
 }
 
-func (c *Client) Execute(ctx context.Context, request requestInterface, endpoint string) (response Response, e error) {
+func (c *Client) execute(ctx context.Context, request requestInterface, endpoint string) (response Response, e error) {
 	request.fillInDefaults()
 	request.setKey(c.Key)
 
 	r, e := c.Ses.R().SetContext(ctx).SetFormData(structToMap(request)).Post(endpoint)
 	if e == nil {
-		if e = json.Unmarshal(r.Body(), &response); e == nil {
-			e = response.GetError()
-		}
+		e = json.Unmarshal(r.Body(), &response)
 	}
 	return
 }
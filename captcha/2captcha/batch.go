@@ -0,0 +1,222 @@
+package twocaptcha
+
+import (
+	"context"
+	"errors"
+	"github.com/k773/utils"
+	"github.com/k773/utils/captcha/poller"
+	"strings"
+	"sync"
+	"time"
+)
+
+// batchMaxIds is the max amount of ids the api accepts in a single res.php?action=get request
+const batchMaxIds = 100
+
+const statusNotReady = "CAPCHA_NOT_READY"
+
+// defaultBatchMaxWait is the max time a submitted task is polled if the poller's MaxWait is not set.
+const defaultBatchMaxWait = 10 * time.Minute
+
+// retryableCodes are the error codes of the temporary batch request failures.
+var retryableCodes = map[string]bool{
+	"ERROR_NO_SLOT_AVAILABLE": true,
+	"ERROR_TOO_MUCH_REQUESTS": true,
+	"MAX_USER_TURN":           true,
+}
+
+/*
+	Task handle
+*/
+
+type TaskHandle struct {
+	c        *Client
+	Id       string
+	TaskType string
+
+	submittedAt time.Time
+	done        chan struct{}
+	response    Response
+	e           error
+}
+
+// Await blocks until the task is solved or failed. If ctx is done, the task is still being polled and Await may be
+// called again; use Cancel to stop polling.
+func (h *TaskHandle) Await(ctx context.Context) (response CaptchaResponse, e error) {
+	response.c, response.Id = h.c, h.Id
+	select {
+	case <-ctx.Done():
+		e = ctx.Err()
+	case <-h.done:
+		response.Response, e = h.response, h.e
+	}
+	return
+}
+
+// Done is closed once the task is solved or failed.
+func (h *TaskHandle) Done() <-chan struct{} {
+	return h.done
+}
+
+// Cancel stops polling the task; pending Await calls receive context.Canceled.
+func (h *TaskHandle) Cancel() {
+	h.c.batch.resolve(h.Id, Response{}, context.Canceled)
+}
+
+/*
+	Client
+*/
+
+// Submit creates the task and returns immediately. Results of all submitted tasks are polled with one batch request
+// per poll interval. A task is failed with TimeoutError after the poller's MaxWait (default: 10m).
+func (c *Client) Submit(ctx context.Context, request requestInterface) (handle *TaskHandle, e error) {
+	r, e := c.Execute(ctx, request, endpointIn)
+	if e != nil {
+		return
+	}
+
+	handle = &TaskHandle{
+		c:           c,
+		Id:          r.Request,
+		TaskType:    request.taskType(),
+		submittedAt: time.Now(),
+		done:        make(chan struct{}),
+	}
+	c.batch.add(c, handle)
+	return
+}
+
+// BatchGet returns the statuses of the tasks in the same order as ids. Unsolved tasks have the CAPCHA_NOT_READY status.
+func (c *Client) BatchGet(ctx context.Context, ids ...string) (statuses []string, e error) {
+	for len(ids) != 0 && e == nil {
+		var chunk = ids[:min(len(ids), batchMaxIds)]
+		ids = ids[len(chunk):]
+
+		var req = &BatchActionRequest{Action: "get", Ids: strings.Join(chunk, ",")}
+		var r Response
+		if r, e = c.execute(ctx, req, endpointRes); e != nil {
+			continue
+		}
+
+		var res = strings.Split(r.Request, "|")
+		if len(res) != len(chunk) {
+			if e = r.GetError(); e == nil {
+				e = &ErrorIncorrectResponseCode{Code: r.Request, Message: "unexpected amount of statuses in the batch response"}
+			}
+			continue
+		}
+		statuses = append(statuses, res...)
+	}
+	return
+}
+
+/*
+	Batcher
+*/
+
+type batcher struct {
+	pending map[string]*TaskHandle
+	running bool
+	l       sync.Mutex
+}
+
+func (b *batcher) add(c *Client, h *TaskHandle) {
+	b.l.Lock()
+	defer b.l.Unlock()
+
+	if b.pending == nil {
+		b.pending = map[string]*TaskHandle{}
+	}
+	b.pending[h.Id] = h
+	if !b.running {
+		b.running = true
+		go b.run(c)
+	}
+}
+
+func (b *batcher) resolve(id string, response Response, e error) {
+	b.l.Lock()
+	defer b.l.Unlock()
+
+	if h, ok := b.pending[id]; ok {
+		delete(b.pending, id)
+		h.response, h.e = response, e
+		close(h.done)
+
+		if history := h.c.poller().History; e == nil && history != nil {
+			history.Add(h.TaskType, time.Since(h.submittedAt))
+		}
+	}
+}
+
+// run polls the pending tasks until there are none. The client's poller defines the intervals and receives the
+// progress; its MaxWait is applied to every task.
+func (b *batcher) run(c *Client) {
+	var p = c.poller()
+	var maxWait = utils.If(p.MaxWait > 0, p.MaxWait, defaultBatchMaxWait)
+	var opts = p.Options
+	opts.MaxWait, opts.History = 0, nil
+
+	_ = poller.New(opts).Poll(context.Background(), "", func(ctx context.Context) (done bool, e error) {
+		var ids = b.pendingIds(maxWait)
+		if len(ids) == 0 {
+			return true, nil
+		}
+
+		statuses, e := c.BatchGet(ctx, ids...)
+		if e != nil {
+			// A temporary failure is retried during the next iteration
+			if !retryable(e) {
+				for _, id := range ids {
+					b.resolve(id, Response{}, e)
+				}
+			}
+			return false, nil
+		}
+		for i, status := range statuses {
+			b.resolveStatus(ids[i], status)
+		}
+		return false, nil
+	})
+}
+
+// pendingIds fails the tasks waiting longer than maxWait and returns the ids of the rest. If there are none, the
+// batcher is marked as stopped.
+func (b *batcher) pendingIds(maxWait time.Duration) []string {
+	b.l.Lock()
+	defer b.l.Unlock()
+
+	var ids = make([]string, 0, len(b.pending))
+	for id, h := range b.pending {
+		if spent := time.Since(h.submittedAt); spent > maxWait {
+			delete(b.pending, id)
+			h.e = &TimeoutError{TimeSpent: spent, TimeAllowed: maxWait}
+			close(h.done)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		b.running = false
+	}
+	return ids
+}
+
+func retryable(e error) bool {
+	var codeError *ErrorIncorrectResponseCode
+	if errors.As(e, &codeError) {
+		return retryableCodes[codeError.Code]
+	}
+	// Network and decoding errors
+	return true
+}
+
+func (b *batcher) resolveStatus(id, status string) {
+	switch {
+	case status == statusNotReady:
+	case strings.HasPrefix(status, "ERROR_"):
+		b.resolve(id, Response{Request: status}, &ErrorIncorrectResponseCode{Code: status})
+	default:
+		b.resolve(id, Response{Status: true, Request: status}, nil)
+	}
+}
@@ -1,9 +1,6 @@
 package twocaptcha
 
-import (
-	"fmt"
-	"time"
-)
+import "github.com/k773/utils/captcha/poller"
 
 type ErrorIncorrectResponseCode struct {
 	Code    string
@@ -17,11 +14,4 @@ func (e *ErrorIncorrectResponseCode) Error() string {
 	return e.Code + ": " + e.Message
 }
 
-type TimeoutError struct {
-	TimeSpent   time.Duration
-	TimeAllowed time.Duration
-}
-
-func (e *TimeoutError) Error() string {
-	return fmt.Sprintf("timeout: spent %v (max allowed: %v)", e.TimeSpent, e.TimeAllowed)
-}
+type TimeoutError = poller.TimeoutError
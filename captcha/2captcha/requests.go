@@ -1,8 +1,10 @@
 package twocaptcha
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"github.com/k773/utils"
+	"github.com/k773/utils/captcha/types"
 	"github.com/k773/utils/fixedPoint"
 	"strconv"
 )
@@ -10,6 +12,7 @@ import (
 type requestInterface interface {
 	setKey(key string)
 	fillInDefaults()
+	taskType() string
 }
 
 /*
@@ -32,6 +35,10 @@ func (c *CommonRequest) setKey(key string) {
 	c.Key = key
 }
 
+func (c *CommonRequest) taskType() string {
+	return ""
+}
+
 /*
 	Common captcha request. Sent with every
 */
@@ -45,6 +52,10 @@ func (c *CommonCaptchaRequest) fillInDefaults() {
 	c.CommonRequest.fillInDefaults()
 }
 
+func (c *CommonCaptchaRequest) taskType() string {
+	return string(c.Method)
+}
+
 type ProxyRequest struct {
 	Cookies   string `json:"cookies"`
 	UserAgent string `json:"userAgent"`
@@ -110,6 +121,43 @@ func (r *RecaptchaRequest) fillInDefaults() {
 	r.Method = MethodRecaptcha
 }
 
+/*
+	Image request
+*/
+
+type ImageRequest struct {
+	CommonCaptchaRequest
+
+	// Body is base64-encoded image
+	Body             string  `json:"body"`
+	Phrase           BoolInt `json:"phrase"`
+	RegSense         BoolInt `json:"regsense"`
+	Numeric          int     `json:"numeric"`
+	Calc             BoolInt `json:"calc"`
+	MinLen           int     `json:"min_len"`
+	MaxLen           int     `json:"max_len"`
+	Lang             string  `json:"lang,omitempty"`
+	TextInstructions string  `json:"textinstructions,omitempty"`
+}
+
+func (r *ImageRequest) fillInDefaults() {
+	r.CommonCaptchaRequest.fillInDefaults()
+
+	r.Method = MethodBase64
+}
+
+func (r *ImageRequest) SetOptions(img []byte, o types.ImageOptions) {
+	r.Body = base64.StdEncoding.EncodeToString(img)
+	r.Phrase = BoolInt(o.Phrase)
+	r.RegSense = BoolInt(o.CaseSensitive)
+	r.Numeric = int(o.Numeric)
+	r.Calc = BoolInt(o.Math)
+	r.MinLen = o.MinLength
+	r.MaxLen = o.MaxLength
+	r.Lang = o.Language
+	r.TextInstructions = o.Instruction
+}
+
 /*
 	Action request
 */
@@ -125,6 +173,22 @@ func (r *ActionRequest) fillInDefaults() {
 	r.CommonRequest.fillInDefaults()
 }
 
+/*
+	Batch action request
+*/
+
+type BatchActionRequest struct {
+	CommonRequest
+
+	// Ids is a comma-separated list of the task ids
+	Ids    string `json:"ids"`
+	Action string `json:"action"`
+}
+
+func (r *BatchActionRequest) fillInDefaults() {
+	r.CommonRequest.fillInDefaults()
+}
+
 /*
 	Method
 */
@@ -134,6 +198,7 @@ type Method string
 const (
 	MethodRecaptcha  Method = "userrecaptcha"
 	MethodFuncaptcha Method = "funcaptcha"
+	MethodBase64     Method = "base64"
 )
 
 /*
@@ -28,7 +28,7 @@ func (r *Response) GetError() error {
 	if r.Status {
 		return nil
 	}
-	if r.Request == "CAPCHA_NOT_READY" {
+	if r.Request == statusNotReady {
 		return nil
 	}
 	return &ErrorIncorrectResponseCode{Code: r.Request, Message: r.ErrorText}
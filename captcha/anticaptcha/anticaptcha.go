@@ -6,10 +6,12 @@ import (
 	"encoding/json"
 	"github.com/go-resty/resty/v2"
 	"github.com/k773/utils"
+	"github.com/k773/utils/captcha/poller"
 	"github.com/k773/utils/captcha/types"
 	"github.com/k773/utils/fixedPoint"
 	"github.com/pkg/errors"
 	"strconv"
+	"sync"
 	"time"
 )
 
@@ -17,6 +19,12 @@ type AntiCaptcha struct {
 	logger *utils.Logger
 	s      *resty.Client
 	Key    string
+	// Poller is used to wait for the task results.
+	// Default: first poll after 20s, then every 20s
+	Poller *poller.Poller
+
+	defaultPoller     *poller.Poller
+	defaultPollerOnce sync.Once
 
 	// hooks, may be nil; if nil, must return true to continue, false - to prevent the execution
 	OnReport func(cr *CaptchaResult, good bool) bool
@@ -30,6 +38,21 @@ func New(s *resty.Client, key string, logger ...*utils.Logger) *AntiCaptcha {
 	return ac
 }
 
+// poller returns Poller or, if it is nil, the default one.
+func (a *AntiCaptcha) poller() *poller.Poller {
+	if a.Poller != nil {
+		return a.Poller
+	}
+	a.defaultPollerOnce.Do(func() {
+		a.defaultPoller = poller.New(poller.Options{
+			InitialDelay: 20 * time.Second,
+			Interval:     20 * time.Second,
+			History:      poller.NewHistory(),
+		})
+	})
+	return a.defaultPoller
+}
+
 const (
 	antiCaptchaCreateTaskUrl    = "https://api.anti-captcha.com/createTask"
 	antiCaptchaGetTaskResultUrl = "https://api.anti-captcha.com/getTaskResult"
@@ -62,10 +85,11 @@ type antiCaptchaTaskRequest struct {
 	Body      string `json:"body,omitempty"`
 	Phrase    bool   `json:"phrase,omitempty"`
 	Case      bool   `json:"case,omitempty"`
-	Numeric   bool   `json:"numeric,omitempty"`
-	Math      int    `json:"math,omitempty"`
+	Numeric   int    `json:"numeric,omitempty"`
+	Math      bool   `json:"math,omitempty"`
 	MinLength int    `json:"minLength,omitempty"`
 	MaxLength int    `json:"maxLength,omitempty"`
+	Comment   string `json:"comment,omitempty"`
 
 	ProxyType     string `json:"proxyType,omitempty"`
 	ProxyAddress  string `json:"proxyAddress,omitempty"`
@@ -147,6 +171,29 @@ func (ae *antiCaptchaErrorResponse) Error() string {
 	return ae.ErrorCode + ": " + ae.ErrorDescription
 }
 
+// wait polls the task until it is solved.
+func (a *AntiCaptcha) wait(ctx context.Context, acType string, taskId int) (antiCaptchaResponse *CaptchaResult, e error) {
+	antiCaptchaResponse = &CaptchaResult{cap: a, TaskType: acType, id: taskId, Status: "processing"}
+	e = a.poller().Poll(ctx, acType, func(ctx context.Context) (done bool, e error) {
+		var resp *resty.Response
+		resp, e = a.s.R().SetContext(ctx).
+			SetBody(antiCaptchaGetTaskResultRequest{
+				ClientKey: a.Key,
+				TaskID:    taskId,
+			}).
+			Post(antiCaptchaGetTaskResultUrl)
+		if e == nil {
+			if e = json.Unmarshal(resp.Body(), antiCaptchaResponse); e == nil {
+				if antiCaptchaResponse.ErrorID != 0 {
+					e = errors.New(antiCaptchaResponse.ErrorCode + ": " + antiCaptchaResponse.ErrorDescription)
+				}
+			}
+		}
+		return antiCaptchaResponse.Status != "processing", e
+	})
+	return
+}
+
 func (a *AntiCaptcha) waitForResponse(ctx context.Context, acType, sitekey, siteUrl string, newTaskResponseB []byte) (res types.CaptchaResult, e error) {
 	var antiCaptchaResponse = new(CaptchaResult)
 
@@ -160,26 +207,7 @@ func (a *AntiCaptcha) waitForResponse(ctx context.Context, acType, sitekey, site
 			e = errors.New(newTaskResponse.ErrorCode + ": " + newTaskResponse.ErrorDescription)
 			_ = utils.SleepWithContext(ctx, 20*time.Second)
 		} else {
-			antiCaptchaResponse.id = newTaskResponse.TaskID
-
-			for e == nil && antiCaptchaResponse.Status == "processing" {
-				if e = utils.SleepWithContext(ctx, 20*time.Second); e == nil {
-					var resp *resty.Response
-					resp, e = a.s.R().SetContext(ctx).
-						SetBody(antiCaptchaGetTaskResultRequest{
-							ClientKey: a.Key,
-							TaskID:    newTaskResponse.TaskID,
-						}).
-						Post(antiCaptchaGetTaskResultUrl)
-					if e == nil {
-						if e = json.Unmarshal(resp.Body(), &antiCaptchaResponse); e == nil {
-							if antiCaptchaResponse.ErrorID != 0 {
-								e = errors.New(antiCaptchaResponse.ErrorCode + ": " + antiCaptchaResponse.ErrorDescription)
-							}
-						}
-					}
-				}
-			}
+			antiCaptchaResponse, e = a.wait(ctx, acType, newTaskResponse.TaskID)
 		}
 	}
 
@@ -313,31 +341,52 @@ func (a *AntiCaptcha) SolveRecaptchaEnterpriseV2Domain(ctx context.Context, webs
 	return antiCaptchaResponse, errors.Wrap(e, "SolveRecaptchaEnterpriseV2")
 }
 
-func (a *AntiCaptcha) SolveImageCaptcha(ctx context.Context, img []byte) (antiCaptchaResponse types.CaptchaResult, e error) {
+// SolveImageCaptcha solves the image captcha; the answer is validated against the first of opts, if any.
+func (a *AntiCaptcha) SolveImageCaptcha(ctx context.Context, img []byte, opts ...types.ImageOptions) (antiCaptchaResponse types.CaptchaResult, e error) {
+	var o types.ImageOptions
+	if len(opts) != 0 {
+		o = opts[0]
+	}
+
 	resp, e := a.s.R().SetContext(ctx).
 		SetBody(antiCaptchaNewTaskRequest{
 			antiCaptchaRequest: antiCaptchaRequest{ClientKey: a.Key},
 			Task: antiCaptchaTaskRequest{
 				Type:      antiCaptchaTypeImageToText,
 				Body:      base64.StdEncoding.EncodeToString(img),
-				Phrase:    false,
-				Case:      false,
-				Numeric:   false,
-				Math:      0,
-				MinLength: 0,
-				MaxLength: 0,
+				Phrase:    o.Phrase,
+				Case:      o.CaseSensitive,
+				Numeric:   int(o.Numeric),
+				Math:      o.Math,
+				MinLength: o.MinLength,
+				MaxLength: o.MaxLength,
+				Comment:   o.Instruction,
 			},
-			SoftID: 994,
+			SoftID:       994,
+			LanguagePool: antiCaptchaLanguagePool(o.Language),
 		}).Post(antiCaptchaCreateTaskUrl)
 
 	if e == nil {
 		antiCaptchaResponse, e = a.waitForResponse(ctx, antiCaptchaTypeImageToText, "none(image)", "none(image)", resp.Body())
+		if e == nil {
+			e = o.Validate(antiCaptchaResponse.Result())
+		}
 	} else {
 		antiCaptchaResponse = new(CaptchaResult)
 	}
 	return antiCaptchaResponse, errors.Wrap(e, "SolveImageCaptcha")
 }
 
+// antiCaptchaLanguagePool converts ISO 639-1 code to the anti-captcha workers pool name.
+func antiCaptchaLanguagePool(language string) string {
+	switch language {
+	case "ru", "uk", "be", "kk":
+		return "rn"
+	default:
+		return "en"
+	}
+}
+
 func (a *AntiCaptcha) SolveFunCaptcha(ctx context.Context, sitePublicKey, siteUrl, data string, proxy *utils.ProxyData) (antiCaptchaResponse types.CaptchaResult, e error) {
 	var taskType = antiCaptchaTypeFunCaptcha
 	if proxy == nil {
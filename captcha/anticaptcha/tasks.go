@@ -0,0 +1,46 @@
+package anticaptcha
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/pkg/errors"
+)
+
+// Task is the task created by Submit; the fields used depend on the task type (see the api docs).
+// The api has no batch status request, so every handle is polled separately.
+type Task = antiCaptchaTaskRequest
+
+// TaskHandle is a created task which result may be awaited later.
+type TaskHandle struct {
+	a        *AntiCaptcha
+	TaskId   int
+	TaskType string
+}
+
+// Submit creates the task and returns immediately.
+func (a *AntiCaptcha) Submit(ctx context.Context, task Task) (handle *TaskHandle, e error) {
+	resp, e := a.s.R().SetContext(ctx).
+		SetBody(antiCaptchaNewTaskRequest{
+			antiCaptchaRequest: antiCaptchaRequest{ClientKey: a.Key},
+			Task:               task,
+			SoftID:             994,
+			LanguagePool:       "en",
+		}).Post(antiCaptchaCreateTaskUrl)
+	if e != nil {
+		return
+	}
+
+	var newTaskResponse antiCaptchaNewTaskResponse
+	if e = json.Unmarshal(resp.Body(), &newTaskResponse); e != nil {
+		return
+	}
+	if newTaskResponse.ErrorID != 0 {
+		return nil, errors.New(newTaskResponse.ErrorCode + ": " + newTaskResponse.ErrorDescription)
+	}
+	return &TaskHandle{a: a, TaskId: newTaskResponse.TaskID, TaskType: task.Type}, nil
+}
+
+// Await polls the task until it is solved, failed or ctx is done. It may be called again after ctx is done.
+func (h *TaskHandle) Await(ctx context.Context) (result *CaptchaResult, e error) {
+	return h.a.wait(ctx, h.TaskType, h.TaskId)
+}
@@ -2,10 +2,11 @@ package capsolvercom
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"github.com/k773/utils"
+	"github.com/k773/utils/captcha/types"
 	"github.com/k773/utils/fixedPoint"
-	"time"
 )
 
 const apiEndpoint = "https://api.capsolver.com"
@@ -19,31 +20,73 @@ func (p *Provider) BalanceUsd(ctx context.Context) (balance fixedPoint.FP, e err
 }
 
 func (p *Provider) Solve(ctx context.Context, task any) (solution Solution, e error) {
+	h, e := p.Submit(ctx, task)
+	if e != nil {
+		return
+	}
+	return h.Await(ctx)
+}
+
+// SolveImage solves the image captcha; the answer is validated against the first of opts, if any.
+// Options not supported by the api are only used for the validation.
+func (p *Provider) SolveImage(ctx context.Context, img []byte, opts ...types.ImageOptions) (text string, e error) {
+	var o types.ImageOptions
+	if len(opts) != 0 {
+		o = opts[0]
+	}
+
+	var task = ImageToTextTask{
+		Type:   TaskTypeImageToTextTask,
+		Body:   base64.StdEncoding.EncodeToString(img),
+		Module: utils.If(o.Numeric == types.ImageNumericOnlyDigits, "number", "common"),
+		Case:   o.CaseSensitive,
+	}
+	solution, e := p.Solve(ctx, task)
+	if e == nil {
+		text = solution.Text
+		e = o.Validate(text)
+	}
+	return
+}
+
+// TaskHandle is a created task which result may be awaited later.
+type TaskHandle struct {
+	p        *Provider
+	TaskId   string
+	TaskType string
+
+	// ready is set if the task was solved instantly
+	ready *Solution
+}
+
+// Submit creates the task and returns immediately.
+func (p *Provider) Submit(ctx context.Context, task any) (handle *TaskHandle, e error) {
 	res, e := p.makeRequest(ctx, "/createTask", BaseTask{Task: &task})
 	if e != nil {
 		return
 	}
+	handle = &TaskHandle{p: p, TaskId: res.TaskId, TaskType: taskTypeOf(task)}
 	if res.isReady() {
-		solution = res.Solution
-		return
+		handle.ready = &res.Solution
 	}
-	return p.wait(ctx, res.TaskId)
+	return
 }
 
-func (p *Provider) wait(ctx context.Context, taskId string) (solution Solution, e error) {
-	const iterEvery = 5 * time.Second
+func (h *TaskHandle) Await(ctx context.Context) (solution Solution, e error) {
+	if h.ready != nil {
+		return *h.ready, nil
+	}
+	return h.p.wait(ctx, h.TaskType, h.TaskId)
+}
+
+func (p *Provider) wait(ctx context.Context, taskType, taskId string) (solution Solution, e error) {
 	var res CapSolverResponse
-	for i := 0; e == nil && res.Status != "ready"; i++ {
-		if e = utils.SleepWithContext(ctx, iterEvery); e != nil {
-			continue
-		}
-		if res, e = p.makeRequest(ctx, "/getTaskResult", BaseTask{TaskId: taskId}); e != nil {
-			continue
+	e = p.poller().Poll(ctx, taskType, func(ctx context.Context) (done bool, e error) {
+		if res, e = p.makeRequest(ctx, "/getTaskResult", BaseTask{TaskId: taskId}); e == nil {
+			done = res.isReady()
 		}
-		if res.isReady() {
-			break
-		}
-	}
+		return
+	})
 	if e == nil {
 		solution = res.Solution
 	}
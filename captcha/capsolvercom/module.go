@@ -1,10 +1,21 @@
 package capsolvercom
 
-import "github.com/go-resty/resty/v2"
+import (
+	"github.com/go-resty/resty/v2"
+	"github.com/k773/utils/captcha/poller"
+	"sync"
+	"time"
+)
 
 type Provider struct {
 	S      *resty.Client
 	ApiKey string
+	// Poller is used to wait for the task results.
+	// Default: poll every 5s
+	Poller *poller.Poller
+
+	defaultPoller     *poller.Poller
+	defaultPollerOnce sync.Once
 }
 
 func New(apiKey string) *Provider {
@@ -13,3 +24,18 @@ func New(apiKey string) *Provider {
 		ApiKey: apiKey,
 	}
 }
+
+// poller returns Poller or, if it is nil, the default one.
+func (p *Provider) poller() *poller.Poller {
+	if p.Poller != nil {
+		return p.Poller
+	}
+	p.defaultPollerOnce.Do(func() {
+		p.defaultPoller = poller.New(poller.Options{
+			InitialDelay: 5 * time.Second,
+			Interval:     5 * time.Second,
+			History:      poller.NewHistory(),
+		})
+	})
+	return p.defaultPoller
+}
@@ -1,5 +1,7 @@
 package capsolvercom
 
+import "reflect"
+
 type BaseTask struct {
 	ClientKey string `json:"clientKey"`
 	TaskId    string `json:"taskId,omitempty"`
@@ -71,3 +73,32 @@ type FunCaptchaTask struct {
 const (
 	TaskTypeFunCaptchaTaskProxyLess TaskType = "FunCaptchaTaskProxyLess"
 )
+
+/*
+	ImageToText
+*/
+
+type ImageToTextTask struct {
+	Type TaskType `json:"type"`
+
+	// Body is base64-encoded image
+	Body string `json:"body"`
+	// Module is the recognition module, e.g. "common" or "number"
+	Module string `json:"module,omitempty"`
+	Case   bool   `json:"case,omitempty"`
+}
+
+// ImageToText tasks
+const (
+	TaskTypeImageToTextTask TaskType = "ImageToTextTask"
+)
+
+func taskTypeOf(task any) string {
+	var v = reflect.Indirect(reflect.ValueOf(task))
+	if v.Kind() == reflect.Struct {
+		if f := v.FieldByName("Type"); f.IsValid() && f.Kind() == reflect.String {
+			return f.String()
+		}
+	}
+	return ""
+}
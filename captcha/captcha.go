@@ -4,3 +4,4 @@ import "github.com/k773/utils/captcha/types"
 
 type SolverInstance = types.CaptchaSolverInstance
 type Result = types.CaptchaResult
+type ImageOptions = types.ImageOptions
@@ -0,0 +1,48 @@
+package poller
+
+import (
+	"sync"
+	"time"
+)
+
+// History keeps an exponentially weighted moving average of the solve times per task type.
+type History struct {
+	// Weight of the newest sample, in (0;1].
+	// Default: 0.2
+	Weight float64
+
+	m map[string]time.Duration
+	l sync.RWMutex
+}
+
+func NewHistory() *History {
+	return &History{Weight: 0.2, m: map[string]time.Duration{}}
+}
+
+func (h *History) Add(taskType string, solvedIn time.Duration) {
+	h.l.Lock()
+	defer h.l.Unlock()
+
+	if h.m == nil {
+		h.m = map[string]time.Duration{}
+	}
+	var weight = h.Weight
+	if weight <= 0 || weight > 1 {
+		weight = 0.2
+	}
+
+	if avg, ok := h.m[taskType]; ok {
+		h.m[taskType] = avg + time.Duration(weight*float64(solvedIn-avg))
+	} else {
+		h.m[taskType] = solvedIn
+	}
+}
+
+// Expected returns the average solve time for the task type; ok is false if no samples were recorded.
+func (h *History) Expected(taskType string) (avg time.Duration, ok bool) {
+	h.l.RLock()
+	defer h.l.RUnlock()
+
+	avg, ok = h.m[taskType]
+	return
+}
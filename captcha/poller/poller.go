@@ -0,0 +1,122 @@
+package poller
+
+import (
+	"context"
+	"fmt"
+	"github.com/k773/utils"
+	"time"
+)
+
+type Options struct {
+	// InitialDelay is slept before the first poll. If History is set and contains the task type, the delay is raised
+	// to the expected solve time multiplied by HistoryFactor.
+	InitialDelay time.Duration
+	// Interval is the delay between two polls.
+	// Default: 5s
+	Interval time.Duration
+	// Multiplier is applied to Interval after every unsuccessful poll. Values <= 1 disable the growth.
+	Multiplier float64
+	// MaxInterval limits the interval growth. 0 = not limited.
+	MaxInterval time.Duration
+	// MaxWait defines max amount of time Poll() is going to wait for the task to be completed.
+	// 0 = duration is not limited.
+	MaxWait time.Duration
+
+	// History is used to adapt the initial delay to the historical solve times. May be nil.
+	History *History
+	// HistoryFactor is the part of the expected solve time that is slept before the first poll.
+	// Default: 0.8
+	HistoryFactor float64
+
+	// OnProgress is called before every sleep. May be nil.
+	OnProgress func(p Progress)
+}
+
+type Progress struct {
+	TaskType string
+	// Attempt is the number of polls already made.
+	Attempt int
+	Elapsed time.Duration
+	// Next is the delay before the next poll.
+	Next time.Duration
+}
+
+type Poller struct {
+	Options
+}
+
+func New(opts Options) *Poller {
+	if opts.Interval <= 0 {
+		opts.Interval = 5 * time.Second
+	}
+	if opts.HistoryFactor <= 0 {
+		opts.HistoryFactor = 0.8
+	}
+	return &Poller{Options: opts}
+}
+
+// Poll calls check until it reports the task as done, returns an error, ctx is done or MaxWait is exceeded.
+// On success the solve time is recorded into History under taskType.
+func (p *Poller) Poll(ctx context.Context, taskType string, check func(ctx context.Context) (done bool, e error)) (e error) {
+	var startedAt = time.Now()
+	var next = p.initialDelay(taskType)
+	var interval = p.Interval
+
+	var done bool
+	for attempt := 0; e == nil && !done; attempt++ {
+		var elapsed = time.Since(startedAt)
+		if p.MaxWait != 0 {
+			if elapsed >= p.MaxWait {
+				e = &TimeoutError{TimeSpent: elapsed, TimeAllowed: p.MaxWait}
+				continue
+			}
+			next = utils.Clamp(next, 0, p.MaxWait-elapsed)
+		}
+		if p.OnProgress != nil {
+			p.OnProgress(Progress{TaskType: taskType, Attempt: attempt, Elapsed: elapsed, Next: next})
+		}
+
+		if e = utils.SleepWithContext(ctx, next); e != nil {
+			continue
+		}
+		if done, e = check(ctx); e != nil || done {
+			continue
+		}
+
+		next, interval = interval, p.grow(interval)
+	}
+
+	if done && e == nil && p.History != nil {
+		p.History.Add(taskType, time.Since(startedAt))
+	}
+	return
+}
+
+func (p *Poller) initialDelay(taskType string) time.Duration {
+	var delay = p.InitialDelay
+	if p.History != nil {
+		if expected, ok := p.History.Expected(taskType); ok {
+			delay = max(delay, time.Duration(float64(expected)*p.HistoryFactor))
+		}
+	}
+	return delay
+}
+
+func (p *Poller) grow(interval time.Duration) time.Duration {
+	if p.Multiplier > 1 {
+		interval = time.Duration(float64(interval) * p.Multiplier)
+	}
+	if p.MaxInterval != 0 {
+		interval = min(interval, p.MaxInterval)
+	}
+	return interval
+}
+
+type TimeoutError struct {
+	TimeSpent   time.Duration
+	TimeAllowed time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("timeout: spent %v (max allowed: %v)", e.TimeSpent, e.TimeAllowed)
+}
@@ -0,0 +1,74 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type ImageNumeric int
+
+const (
+	ImageNumericAny ImageNumeric = iota
+	ImageNumericOnlyDigits
+	ImageNumericOnlyLetters
+)
+
+// ImageOptions describes the text on the image captcha. Zero value = no constraints.
+type ImageOptions struct {
+	// CaseSensitive marks the answer as case-sensitive
+	CaseSensitive bool
+	Numeric       ImageNumeric
+	// Phrase marks the answer as containing at least two words separated by a space
+	Phrase bool
+	// Math marks the image as an arithmetic expression which result is the answer
+	Math bool
+	// MinLength and MaxLength limit the answer length in runes; 0 = not limited
+	MinLength int
+	MaxLength int
+	// Language is the ISO 639-1 code of the answer language, e.g. "en"
+	Language string
+	// Instruction is the text shown to the worker
+	Instruction string
+}
+
+// Validate checks the solved text against the constraints which can be verified locally.
+func (o *ImageOptions) Validate(text string) error {
+	var length = utf8.RuneCountInString(text)
+	if length == 0 {
+		return &InvalidImageAnswerError{Text: text, Reason: "empty answer"}
+	}
+	if o.MinLength != 0 && length < o.MinLength {
+		return &InvalidImageAnswerError{Text: text, Reason: fmt.Sprintf("shorter than %v", o.MinLength)}
+	}
+	if o.MaxLength != 0 && length > o.MaxLength {
+		return &InvalidImageAnswerError{Text: text, Reason: fmt.Sprintf("longer than %v", o.MaxLength)}
+	}
+	if o.Phrase && len(strings.Fields(text)) < 2 {
+		return &InvalidImageAnswerError{Text: text, Reason: "not a phrase"}
+	}
+
+	for _, r := range text {
+		switch {
+		case unicode.IsSpace(r):
+			continue
+		case o.Math && !unicode.IsDigit(r) && r != '-':
+			return &InvalidImageAnswerError{Text: text, Reason: "math answer must be a number"}
+		case o.Numeric == ImageNumericOnlyDigits && !unicode.IsDigit(r):
+			return &InvalidImageAnswerError{Text: text, Reason: "digits expected"}
+		case o.Numeric == ImageNumericOnlyLetters && unicode.IsDigit(r):
+			return &InvalidImageAnswerError{Text: text, Reason: "letters expected"}
+		}
+	}
+	return nil
+}
+
+type InvalidImageAnswerError struct {
+	Text   string
+	Reason string
+}
+
+func (e *InvalidImageAnswerError) Error() string {
+	return fmt.Sprintf("invalid image captcha answer %q: %v", e.Text, e.Reason)
+}
@@ -0,0 +1,75 @@
+package cookiejar
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/k773/utils/files"
+	"os"
+	"sync"
+)
+
+// FileStorage is a Storage keeping every group of a single jar in one JSON
+// file. The file is replaced atomically on every save.
+type FileStorage struct {
+	path string
+
+	mu     sync.Mutex
+	groups map[string]json.RawMessage
+}
+
+func NewFileStorage(path string) *FileStorage {
+	return &FileStorage{path: path}
+}
+
+func (f *FileStorage) Load() (map[string][]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.load(); err != nil {
+		return nil, err
+	}
+	groups := make(map[string][]byte, len(f.groups))
+	for key, group := range f.groups {
+		groups[key] = group
+	}
+	return groups, nil
+}
+
+func (f *FileStorage) Save(groups map[string][]byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// The groups existing in the file must not be lost if Save is called
+	// without Load.
+	if f.groups == nil {
+		if err := f.load(); err != nil {
+			return err
+		}
+	}
+	for key, group := range groups {
+		if group == nil {
+			delete(f.groups, key)
+		} else {
+			f.groups[key] = group
+		}
+	}
+
+	data, err := json.Marshal(f.groups)
+	if err != nil {
+		return err
+	}
+	return files.WriteFileAtomic(f.path, data, 0600)
+}
+
+// load reads the file into f.groups. It must be called with f.mu held.
+func (f *FileStorage) load() error {
+	f.groups = make(map[string]json.RawMessage)
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &f.groups)
+}
@@ -0,0 +1,361 @@
+package cookiejar
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	errMalformedLine = errors.New("cookiejar: malformed cookies.txt line")
+	errUnexportable  = errors.New("cookiejar: cookie can not be written to cookies.txt")
+)
+
+// sortedEntries returns all the entries ordered by domain, path and name. It
+// must be called with j.mu held.
+func (j *Jar) sortedEntries() []entry {
+	var all []entry
+	for _, submap := range j.entries {
+		for _, e := range submap {
+			all = append(all, e)
+		}
+	}
+	sort.Slice(all, func(i, k int) bool {
+		return all[i].id() < all[k].id()
+	})
+	return all
+}
+
+// importEntry stores e as if it was received now, keeping the creation time
+// of a cookie it replaces. A cookie exceeding the size limit is skipped. It
+// must be called with j.mu held.
+func (j *Jar) importEntry(e entry, now time.Time) {
+	if j.tooLarge(&e) {
+		return
+	}
+	if e.Path == "" || e.Path[0] != '/' {
+		e.Path = "/"
+	}
+	key := jarKey(e.Domain, j.psList)
+	submap := j.entries[key]
+	if submap == nil {
+		submap = make(map[string]entry)
+		j.entries[key] = submap
+	}
+
+	id := e.id()
+	if old, ok := submap[id]; ok {
+		e.Creation = old.Creation
+		e.seqNum = old.seqNum
+	} else {
+		e.Creation = now
+		e.seqNum = j.nextSeqNum
+		j.nextSeqNum++
+		j.addCount(1)
+	}
+	e.LastAccess = now
+	submap[id] = e
+	j.markDirty(key)
+	j.notify(ChangeSet, &e)
+	j.enforceLimits(key, now)
+}
+
+// canonicalDomain lower-cases the domain and strips the leading dot used by
+// the external formats to mark the domain cookies.
+func canonicalDomain(domain string) (string, error) {
+	domain = strings.TrimPrefix(domain, ".")
+	if domain == "" {
+		return "", errMalformedDomain
+	}
+	return canonicalHost(domain)
+}
+
+/*
+	Netscape cookies.txt
+*/
+
+const netscapeHttpOnlyPrefix = "#HttpOnly_"
+
+// ExportNetscape writes every cookie in the Netscape cookies.txt format used by
+// curl and wget. Session cookies are written with the zero expiration time.
+// The partition key of a partitioned cookie is written as the eighth field,
+// so the tools not supporting partitions skip such lines. The cookies having a
+// tab or a line break in a field can not be read back, so they are skipped
+// and reported by the returned error.
+func (j *Jar) ExportNetscape(w io.Writer) error {
+	j.mu.RLock()
+	all := j.sortedEntries()
+	j.mu.RUnlock()
+
+	var errs []error
+	bw := bufio.NewWriter(w)
+	_, _ = bw.WriteString("# Netscape HTTP Cookie File\n")
+	for _, e := range all {
+		if strings.ContainsAny(e.Domain+e.Path+e.Name+e.Value+e.PartitionKey, "\t\r\n") {
+			errs = append(errs, fmt.Errorf("%w: %s %s", errUnexportable, e.Domain, e.Name))
+			continue
+		}
+		domain := e.Domain
+		if !e.HostOnly {
+			domain = "." + domain
+		}
+		if e.HttpOnly {
+			domain = netscapeHttpOnlyPrefix + domain
+		}
+		var expires int64
+		if e.Persistent {
+			expires = e.Expires.Unix()
+		}
+		_, _ = fmt.Fprintf(bw, "%s\t%s\t%s\t%s\t%d\t%s\t%s",
+			domain, netscapeBool(!e.HostOnly), e.Path, netscapeBool(e.Secure), expires, e.Name, e.Value)
+		if e.PartitionKey != "" {
+			_, _ = fmt.Fprintf(bw, "\t%s", e.PartitionKey)
+		}
+		_ = bw.WriteByte('\n')
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	return errors.Join(errs...)
+}
+
+// ImportNetscape reads the cookies in the Netscape cookies.txt format, with the
+// optional partition key written by ExportNetscape. The expired cookies are
+// skipped. n is the amount of cookies imported.
+func (j *Jar) ImportNetscape(r io.Reader) (n int, err error) {
+	now := time.Now()
+	var imported []entry
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		var httpOnly bool
+		if strings.HasPrefix(text, netscapeHttpOnlyPrefix) {
+			text, httpOnly = text[len(netscapeHttpOnlyPrefix):], true
+		}
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Split(text, "\t")
+		if len(fields) != 7 && len(fields) != 8 {
+			return 0, fmt.Errorf("%w %d", errMalformedLine, line)
+		}
+		e := entry{
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			HttpOnly: httpOnly,
+			HostOnly: !strings.EqualFold(fields[1], "TRUE"),
+			Name:     fields[5],
+			Value:    fields[6],
+		}
+		if len(fields) == 8 {
+			e.PartitionKey = fields[7]
+		}
+		if e.Domain, err = canonicalDomain(fields[0]); err != nil {
+			return 0, fmt.Errorf("%w %d: %v", errMalformedLine, line, err)
+		}
+		var expires int64
+		if expires, err = parseNetscapeExpiry(fields[4]); err != nil {
+			return 0, fmt.Errorf("%w %d: %v", errMalformedLine, line, err)
+		}
+		if !setEntryExpiry(&e, expires, now) {
+			continue
+		}
+		imported = append(imported, e)
+	}
+	if err = scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	j.mu.Lock()
+	defer j.unlockAndEmit()
+	for _, e := range imported {
+		j.importEntry(e, now)
+	}
+	return len(imported), nil
+}
+
+// parseNetscapeExpiry parses the expiration time in seconds, which some
+// exporters write with a fractional part.
+func parseNetscapeExpiry(s string) (int64, error) {
+	if expires, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return expires, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return 0, fmt.Errorf("expiration time out of range: %s", s)
+	}
+	return int64(f), nil
+}
+
+func netscapeBool(b bool) string {
+	if b {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+// setEntryExpiry sets the expiration of e from unix seconds, 0 meaning a
+// session cookie. It reports false if the cookie is already expired.
+func setEntryExpiry(e *entry, unix int64, now time.Time) bool {
+	if unix == 0 {
+		e.Expires, e.Persistent = endOfTime, false
+		return true
+	}
+	e.Expires, e.Persistent = time.Unix(unix, 0), true
+	return e.Expires.After(now)
+}
+
+/*
+	Browser extensions JSON
+*/
+
+// BrowserCookie is the cookie representation used by the common browser
+// extensions (EditThisCookie, Cookie-Editor) and chrome.cookies api.
+type BrowserCookie struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Domain   string `json:"domain"`
+	HostOnly bool   `json:"hostOnly"`
+	Path     string `json:"path"`
+	Secure   bool   `json:"secure"`
+	HttpOnly bool   `json:"httpOnly"`
+	// SameSite is one of "no_restriction", "lax", "strict", "unspecified"
+	SameSite string `json:"sameSite"`
+	Session  bool   `json:"session"`
+	// ExpirationDate is unix seconds; omitted for the session cookies
+	ExpirationDate float64 `json:"expirationDate,omitempty"`
+	StoreId        string  `json:"storeId,omitempty"`
+	// PartitionKey is set for the partitioned (CHIPS) cookies
+	PartitionKey *BrowserPartitionKey `json:"partitionKey,omitempty"`
+}
+
+type BrowserPartitionKey struct {
+	// TopLevelSite is the schemeful site, e.g. "https://example.com"
+	TopLevelSite string `json:"topLevelSite"`
+}
+
+// BrowserCookies returns every cookie in the browser extensions format.
+func (j *Jar) BrowserCookies() []BrowserCookie {
+	j.mu.RLock()
+	all := j.sortedEntries()
+	j.mu.RUnlock()
+
+	res := make([]BrowserCookie, len(all))
+	for i, e := range all {
+		c := BrowserCookie{
+			Name:     e.Name,
+			Value:    e.Value,
+			Domain:   e.Domain,
+			HostOnly: e.HostOnly,
+			Path:     e.Path,
+			Secure:   e.Secure,
+			HttpOnly: e.HttpOnly,
+			SameSite: browserSameSite(e.SameSite),
+			Session:  !e.Persistent,
+		}
+		if !e.HostOnly {
+			c.Domain = "." + c.Domain
+		}
+		if e.Persistent {
+			c.ExpirationDate = float64(e.Expires.Unix())
+		}
+		if e.PartitionKey != "" {
+			c.PartitionKey = &BrowserPartitionKey{TopLevelSite: e.PartitionKey}
+		}
+		res[i] = c
+	}
+	return res
+}
+
+// ExportBrowserJSON returns every cookie as the JSON array used by the browser
+// extensions.
+func (j *Jar) ExportBrowserJSON() ([]byte, error) {
+	return json.Marshal(j.BrowserCookies())
+}
+
+// ImportBrowserCookies stores the cookies. The expired cookies are skipped. n
+// is the amount of cookies imported.
+func (j *Jar) ImportBrowserCookies(cookies []BrowserCookie) (n int, err error) {
+	now := time.Now()
+	imported := make([]entry, 0, len(cookies))
+	for _, c := range cookies {
+		e := entry{
+			Name:     c.Name,
+			Value:    c.Value,
+			Path:     c.Path,
+			Secure:   c.Secure,
+			HttpOnly: c.HttpOnly,
+			HostOnly: c.HostOnly,
+			SameSite: jarSameSite(c.SameSite),
+		}
+		if c.PartitionKey != nil {
+			e.PartitionKey = c.PartitionKey.TopLevelSite
+		}
+		if e.Domain, err = canonicalDomain(c.Domain); err != nil {
+			return 0, err
+		}
+		var expires int64
+		if !c.Session {
+			expires = int64(math.Ceil(c.ExpirationDate))
+		}
+		if !setEntryExpiry(&e, expires, now) {
+			continue
+		}
+		imported = append(imported, e)
+	}
+
+	j.mu.Lock()
+	defer j.unlockAndEmit()
+	for _, e := range imported {
+		j.importEntry(e, now)
+	}
+	return len(imported), nil
+}
+
+// ImportBrowserJSON parses the JSON array used by the browser extensions and
+// stores the cookies.
+func (j *Jar) ImportBrowserJSON(data []byte) (n int, err error) {
+	var cookies []BrowserCookie
+	if err = json.Unmarshal(data, &cookies); err != nil {
+		return 0, err
+	}
+	return j.ImportBrowserCookies(cookies)
+}
+
+func browserSameSite(sameSite string) string {
+	switch sameSite {
+	case "SameSite=Strict":
+		return "strict"
+	case "SameSite=Lax":
+		return "lax"
+	case "SameSite=None":
+		return "no_restriction"
+	default:
+		return "unspecified"
+	}
+}
+
+func jarSameSite(sameSite string) string {
+	switch strings.ToLower(sameSite) {
+	case "strict":
+		return "SameSite=Strict"
+	case "lax":
+		return "SameSite=Lax"
+	case "no_restriction", "none":
+		return "SameSite=None"
+	default:
+		return ""
+	}
+}
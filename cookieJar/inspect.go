@@ -0,0 +1,194 @@
+package cookiejar
+
+import (
+	"sort"
+	"time"
+)
+
+// Cookie is a stored cookie as seen by the inspection methods of Jar.
+type Cookie struct {
+	Name       string
+	Value      string
+	Domain     string
+	Path       string
+	SameSite   string
+	Secure     bool
+	HttpOnly   bool
+	Persistent bool
+	HostOnly   bool
+	Expires    time.Time
+	Creation   time.Time
+	LastAccess time.Time
+	// PartitionKey is the top-level site of a partitioned cookie.
+	PartitionKey string
+}
+
+func (e *entry) cookie() Cookie {
+	return Cookie{
+		Name:         e.Name,
+		Value:        e.Value,
+		Domain:       e.Domain,
+		Path:         e.Path,
+		SameSite:     e.SameSite,
+		Secure:       e.Secure,
+		HttpOnly:     e.HttpOnly,
+		Persistent:   e.Persistent,
+		HostOnly:     e.HostOnly,
+		Expires:      e.Expires,
+		Creation:     e.Creation,
+		LastAccess:   e.LastAccess,
+		PartitionKey: e.PartitionKey,
+	}
+}
+
+// expired reports whether e has to be removed at now.
+func (e *entry) expired(now time.Time) bool {
+	return e.Persistent && !e.Expires.After(now)
+}
+
+type ChangeKind int
+
+const (
+	// ChangeSet is reported when a cookie is created or replaced.
+	ChangeSet ChangeKind = iota
+	// ChangeDelete is reported when a cookie is removed by the server or
+	// by Delete.
+	ChangeDelete
+	// ChangeExpire is reported when an expired cookie is removed.
+	ChangeExpire
+	// ChangeEvict is reported when a cookie is removed to fit the limits of
+	// the jar.
+	ChangeEvict
+)
+
+type Change struct {
+	Kind   ChangeKind
+	Cookie Cookie
+}
+
+// SetOnChange sets the hook called after every change of the jar's cookies.
+// The hook is called outside of the jar's lock, in the goroutine that made the
+// change, so it may use the jar. A nil f removes the hook.
+func (j *Jar) SetOnChange(f func(c Change)) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.onChange = f
+}
+
+// notify records the change for the hook. It must be called with j.mu held.
+func (j *Jar) notify(kind ChangeKind, e *entry) {
+	if j.onChange != nil {
+		j.changes = append(j.changes, Change{Kind: kind, Cookie: e.cookie()})
+	}
+}
+
+// unlockAndEmit releases j.mu and calls the hook for the changes recorded
+// while it was held, so every call emits only its own changes. It must be
+// called with j.mu held by the method that made the changes.
+func (j *Jar) unlockAndEmit() {
+	changes, onChange := j.changes, j.onChange
+	j.changes = nil
+	j.mu.Unlock()
+
+	for _, c := range changes {
+		onChange(c)
+	}
+}
+
+// All returns every unexpired cookie ordered by domain, path and name.
+func (j *Jar) All() []Cookie {
+	now := time.Now()
+
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	var res []Cookie
+	for _, e := range j.sortedEntries() {
+		if !e.expired(now) {
+			res = append(res, e.cookie())
+		}
+	}
+	return res
+}
+
+// Find returns the unexpired cookies named name stored for exactly domain, with
+// any path. An empty name matches every cookie of the domain.
+func (j *Jar) Find(domain, name string) []Cookie {
+	domain, err := canonicalDomain(domain)
+	if err != nil {
+		return nil
+	}
+	now := time.Now()
+
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	var res []Cookie
+	for _, e := range j.entries[jarKey(domain, j.psList)] {
+		if e.Domain == domain && (name == "" || e.Name == name) && !e.expired(now) {
+			res = append(res, e.cookie())
+		}
+	}
+	sort.Slice(res, func(i, k int) bool {
+		return res[i].Path < res[k].Path
+	})
+	return res
+}
+
+// Delete removes the cookie identified by its domain, path and name from every
+// partition. It reports whether the cookie existed.
+func (j *Jar) Delete(domain, path, name string) (deleted bool) {
+	domain, err := canonicalDomain(domain)
+	if err != nil {
+		return false
+	}
+	key := jarKey(domain, j.psList)
+
+	j.mu.Lock()
+	defer j.unlockAndEmit()
+
+	for id, e := range j.entries[key] {
+		if e.Domain == domain && e.Path == path && e.Name == name {
+			j.removeEntry(key, id, ChangeDelete, &e)
+			deleted = true
+		}
+	}
+	return deleted
+}
+
+// DeleteExpired removes every expired cookie and returns their amount.
+func (j *Jar) DeleteExpired() (n int) {
+	now := time.Now()
+
+	j.mu.Lock()
+	defer j.unlockAndEmit()
+
+	return j.removeExpired(now)
+}
+
+// Domains returns the keys the cookies are grouped by (eTLD+1, a host name or
+// an IP address), sorted.
+func (j *Jar) Domains() []string {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	keys := make([]string, 0, len(j.entries))
+	for key := range j.entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// removeEntry deletes the entry and the emptied group. It must be called with
+// j.mu held.
+func (j *Jar) removeEntry(key, id string, kind ChangeKind, e *entry) {
+	delete(j.entries[key], id)
+	j.addCount(-1)
+	if len(j.entries[key]) == 0 {
+		delete(j.entries, key)
+	}
+	j.markDirty(key)
+	j.notify(kind, e)
+}
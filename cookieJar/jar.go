@@ -17,6 +17,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -49,14 +50,49 @@ type PublicSuffixList interface {
 }
 
 // Options are the options for creating a new Jar.
+//
+// The cookie limits are applied by default, like the browsers do: a zero
+// MaxCookiesPerDomain, MaxCookies or MaxCookieSize means the default limit,
+// not no limit as in the earlier versions, so even New(nil) evicts cookies and
+// rejects the larger ones. Set a negative value to disable a limit.
 type Options struct {
 	// PublicSuffixList is the public suffix list that determines whether
 	// an HTTP server can set a cookie for a domain.
 	//
 	// A nil value is valid and may be useful for testing but it is not
 	// secure: it means that the HTTP server for foo.co.uk can set a cookie
-	// for bar.co.uk.
+	// for bar.co.uk. Use DefaultPublicSuffixList unless there is a reason
+	// not to.
 	PublicSuffixList PublicSuffixList
+
+	// Storage persists the cookies. If set, the jar is loaded from it by
+	// New and every change is saved to it after FlushDelay.
+	//
+	// A nil value means the cookies are kept in memory only.
+	Storage Storage
+
+	// FlushDelay is the delay between a change and saving it to Storage;
+	// the changes made during the delay are saved together.
+	// Default: 1s
+	FlushDelay time.Duration
+
+	// MaxCookiesPerDomain limits the amount of cookies stored per eTLD+1;
+	// the least recently used cookies are evicted when it is exceeded.
+	// A negative value means no limit.
+	// Default: DefaultMaxCookiesPerDomain
+	MaxCookiesPerDomain int
+
+	// MaxCookies limits the amount of cookies in the jar; the least recently
+	// used cookies are evicted when it is exceeded.
+	// A negative value means no limit.
+	// Default: DefaultMaxCookies
+	MaxCookies int
+
+	// MaxCookieSize limits the length of a cookie's name and value; larger
+	// cookies are rejected.
+	// A negative value means no limit.
+	// Default: DefaultMaxCookieSize
+	MaxCookieSize int
 }
 
 // Jar implements the http.CookieJar interface from the net/http package.
@@ -64,25 +100,62 @@ type Jar struct {
 	psList PublicSuffixList
 
 	// mu locks the remaining fields.
-	mu sync.Mutex
+	mu sync.RWMutex
 
 	// entries is a set of entries, keyed by their eTLD+1 and subkeyed by
 	// their name/domain/path.
 	entries map[string]map[string]entry
+	// count is the amount of the entries, kept for the MaxCookies limit.
+	count int
+	// total is the amount of the entries of every shard of a ShardedJar; nil
+	// for a standalone jar.
+	total *atomic.Int64
 
 	// nextSeqNum is the next sequence number assigned to a new cookie
 	// created SetCookies.
 	nextSeqNum uint64
+
+	storage    Storage
+	flushDelay time.Duration
+	// dirty is the set of the entries keys changed since the last flush.
+	dirty      map[string]struct{}
+	flushTimer *time.Timer
+	// flushMu serializes the flushes.
+	flushMu sync.Mutex
+
+	onChange func(c Change)
+	// changes are waiting to be passed to onChange after j.mu is released.
+	changes []Change
+
+	maxPerDomain, maxCookies, maxCookieSize int
+	// stopPurge stops the purge started by StartPurge.
+	stopPurge func()
 }
 
 // New returns a new cookie jar. A nil *Options is equivalent to a zero
-// Options.
+// Options. If Options.Storage is set, the jar is loaded from it.
 func New(o *Options) (*Jar, error) {
 	jar := &Jar{
-		entries: make(map[string]map[string]entry),
+		entries:       make(map[string]map[string]entry),
+		flushDelay:    defaultFlushDelay,
+		maxPerDomain:  DefaultMaxCookiesPerDomain,
+		maxCookies:    DefaultMaxCookies,
+		maxCookieSize: DefaultMaxCookieSize,
 	}
 	if o != nil {
 		jar.psList = o.PublicSuffixList
+		jar.storage = o.Storage
+		if o.FlushDelay > 0 {
+			jar.flushDelay = o.FlushDelay
+		}
+		jar.maxPerDomain = limit(o.MaxCookiesPerDomain, DefaultMaxCookiesPerDomain)
+		jar.maxCookies = limit(o.MaxCookies, DefaultMaxCookies)
+		jar.maxCookieSize = limit(o.MaxCookieSize, DefaultMaxCookieSize)
+	}
+	if jar.storage != nil {
+		if err := jar.load(); err != nil {
+			return nil, err
+		}
 	}
 	return jar, nil
 }
@@ -112,6 +185,9 @@ type entry struct {
 	Expires    time.Time
 	Creation   time.Time
 	LastAccess time.Time
+	// PartitionKey is the top-level site of a partitioned (CHIPS) cookie;
+	// empty for the unpartitioned cookies.
+	PartitionKey string `json:",omitempty"`
 
 	// seqNum is a sequence number so that Cookies returns cookies in a
 	// deterministic order, even for cookies that have equal Path length and
@@ -119,8 +195,12 @@ type entry struct {
 	seqNum uint64
 }
 
-// id returns the domain;path;name triple of e as an id.
+// id returns the domain;path;name triple of e as an id. The partition key is
+// appended for the partitioned cookies.
 func (e *entry) id() string {
+	if e.PartitionKey != "" {
+		return fmt.Sprintf("%s;%s;%s;%s", e.Domain, e.Path, e.Name, e.PartitionKey)
+	}
 	return fmt.Sprintf("%s;%s;%s", e.Domain, e.Path, e.Name)
 }
 
@@ -165,11 +245,12 @@ func hasDotSuffix(s, suffix string) bool {
 //
 // It returns an empty slice if the URL's scheme is not HTTP or HTTPS.
 func (j *Jar) Cookies(u *url.URL) (cookies []*http.Cookie) {
-	return j.cookies(u, time.Now())
+	return j.cookies(u, nil, time.Now())
 }
 
-// cookies is like Cookies but takes the current time as a parameter.
-func (j *Jar) cookies(u *url.URL, now time.Time) (cookies []*http.Cookie) {
+// cookies is like Cookies but takes the site context and the current time as
+// parameters. A nil sc means a same-site top-level request.
+func (j *Jar) cookies(u *url.URL, sc *SiteContext, now time.Time) (cookies []*http.Cookie) {
 	if u.Scheme != "http" && u.Scheme != "https" {
 		return cookies
 	}
@@ -178,30 +259,37 @@ func (j *Jar) cookies(u *url.URL, now time.Time) (cookies []*http.Cookie) {
 		return cookies
 	}
 	key := jarKey(host, j.psList)
+	https := u.Scheme == "https"
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	si := j.resolveSite(sc, u.Scheme, host)
+
+	if cookies, ok := j.cookiesFast(key, https, host, path, &si, now); ok {
+		return cookies
+	}
 
 	j.mu.Lock()
-	defer j.mu.Unlock()
+	defer j.unlockAndEmit()
 
 	submap := j.entries[key]
 	if submap == nil {
 		return cookies
 	}
 
-	https := u.Scheme == "https"
-	path := u.Path
-	if path == "" {
-		path = "/"
-	}
-
 	modified := false
 	var selected []entry
 	for id, e := range submap {
-		if e.Persistent && !e.Expires.After(now) {
+		if e.expired(now) {
 			delete(submap, id)
+			j.addCount(-1)
 			modified = true
+			j.markDirty(key)
+			j.notify(ChangeExpire, &e)
 			continue
 		}
-		if !e.shouldSend(https, host, path) {
+		if !e.shouldSend(https, host, path) || !e.sameSiteAllowsSend(&si) {
 			continue
 		}
 		e.LastAccess = now
@@ -216,9 +304,39 @@ func (j *Jar) cookies(u *url.URL, now time.Time) (cookies []*http.Cookie) {
 			j.entries[key] = submap
 		}
 	}
+	return sortedCookies(selected)
+}
 
-	// sort according to RFC 6265 section 5.4 point 2: by longest
-	// path and then by earliest creation time.
+// accessGranularity is the precision of entry.LastAccess: it is refreshed at
+// most once per accessGranularity, so the repeated requests only take the
+// read lock.
+const accessGranularity = time.Second
+
+// cookiesFast is the read-only path of cookies. ok is false if the group has
+// expired cookies or cookies whose LastAccess has to be refreshed.
+func (j *Jar) cookiesFast(key string, https bool, host, path string, si *siteInfo, now time.Time) (cookies []*http.Cookie, ok bool) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	var selected []entry
+	for _, e := range j.entries[key] {
+		if e.expired(now) {
+			return nil, false
+		}
+		if !e.shouldSend(https, host, path) || !e.sameSiteAllowsSend(si) {
+			continue
+		}
+		if now.Sub(e.LastAccess) >= accessGranularity {
+			return nil, false
+		}
+		selected = append(selected, e)
+	}
+	return sortedCookies(selected), true
+}
+
+// sortedCookies sorts the entries according to RFC 6265 section 5.4 point 2:
+// by longest path and then by earliest creation time.
+func sortedCookies(selected []entry) (cookies []*http.Cookie) {
 	sort.Slice(selected, func(i, j int) bool {
 		s := selected
 		if len(s[i].Path) != len(s[j].Path) {
@@ -232,7 +350,6 @@ func (j *Jar) cookies(u *url.URL, now time.Time) (cookies []*http.Cookie) {
 	for _, e := range selected {
 		cookies = append(cookies, &http.Cookie{Name: e.Name, Value: e.Value})
 	}
-
 	return cookies
 }
 
@@ -240,11 +357,12 @@ func (j *Jar) cookies(u *url.URL, now time.Time) (cookies []*http.Cookie) {
 //
 // It does nothing if the URL's scheme is not HTTP or HTTPS.
 func (j *Jar) SetCookies(u *url.URL, cookies []*http.Cookie) {
-	j.setCookies(u, cookies, time.Now())
+	j.setCookies(u, cookies, nil, time.Now())
 }
 
-// setCookies is like SetCookies but takes the current time as parameter.
-func (j *Jar) setCookies(u *url.URL, cookies []*http.Cookie, now time.Time) {
+// setCookies is like SetCookies but takes the site context and the current
+// time as parameters. A nil sc means a same-site top-level request.
+func (j *Jar) setCookies(u *url.URL, cookies []*http.Cookie, sc *SiteContext, now time.Time) {
 	if len(cookies) == 0 {
 		return
 	}
@@ -257,24 +375,28 @@ func (j *Jar) setCookies(u *url.URL, cookies []*http.Cookie, now time.Time) {
 	}
 	key := jarKey(host, j.psList)
 	defPath := defaultPath(u.Path)
+	https := u.Scheme == "https"
+	si := j.resolveSite(sc, u.Scheme, host)
 
 	j.mu.Lock()
-	defer j.mu.Unlock()
+	defer j.unlockAndEmit()
 
 	submap := j.entries[key]
 
 	modified := false
 	for _, cookie := range cookies {
-		e, remove, err := j.newEntry(cookie, now, defPath, host)
+		e, remove, err := j.newEntry(cookie, now, defPath, host, https, &si)
 		if err != nil {
 			continue
 		}
 		id := e.id()
 		if remove {
 			if submap != nil {
-				if _, ok := submap[id]; ok {
+				if old, ok := submap[id]; ok {
 					delete(submap, id)
+					j.addCount(-1)
 					modified = true
+					j.notify(ChangeDelete, &old)
 				}
 			}
 			continue
@@ -290,10 +412,12 @@ func (j *Jar) setCookies(u *url.URL, cookies []*http.Cookie, now time.Time) {
 			e.Creation = now
 			e.seqNum = j.nextSeqNum
 			j.nextSeqNum++
+			j.addCount(1)
 		}
 		e.LastAccess = now
 		submap[id] = e
 		modified = true
+		j.notify(ChangeSet, &e)
 	}
 
 	if modified {
@@ -302,6 +426,8 @@ func (j *Jar) setCookies(u *url.URL, cookies []*http.Cookie, now time.Time) {
 		} else {
 			j.entries[key] = submap
 		}
+		j.markDirty(key)
+		j.enforceLimits(key, now)
 	}
 }
 
@@ -391,14 +517,16 @@ func defaultPath(path string) string {
 
 // newEntry creates an entry from an http.Cookie c. now is the current time and
 // is compared to c.Expires to determine deletion of c. defPath and host are the
-// default-path and the canonical host name of the URL c was received from.
+// default-path and the canonical host name of the URL c was received from,
+// https reports whether the URL is secure and si is its site context.
 //
 // remove records whether the jar should delete this cookie, as it has already
 // expired with respect to now. In this case, e may be incomplete, but it will
 // be valid to call e.id (which depends on e's Name, Domain and Path).
 //
-// A malformed c.Domain will result in an error.
-func (j *Jar) newEntry(c *http.Cookie, now time.Time, defPath, host string) (e entry, remove bool, err error) {
+// A malformed c.Domain, a cookie exceeding Options.MaxCookieSize or a cookie
+// violating RFC 6265bis (see checkRFC6265bis) will result in an error.
+func (j *Jar) newEntry(c *http.Cookie, now time.Time, defPath, host string, https bool, si *siteInfo) (e entry, remove bool, err error) {
 	e.Name = c.Name
 
 	if c.Path == "" || c.Path[0] != '/' {
@@ -411,6 +539,9 @@ func (j *Jar) newEntry(c *http.Cookie, now time.Time, defPath, host string) (e e
 	if err != nil {
 		return e, false, err
 	}
+	if partitioned(c) {
+		e.PartitionKey = si.partitionKey
+	}
 
 	// MaxAge takes precedence over Expires.
 	if c.MaxAge < 0 {
@@ -432,6 +563,9 @@ func (j *Jar) newEntry(c *http.Cookie, now time.Time, defPath, host string) (e e
 	}
 
 	e.Value = c.Value
+	if j.tooLarge(&e) {
+		return e, false, errCookieTooLarge
+	}
 	e.Secure = c.Secure
 	e.HttpOnly = c.HttpOnly
 
@@ -442,8 +576,13 @@ func (j *Jar) newEntry(c *http.Cookie, now time.Time, defPath, host string) (e e
 		e.SameSite = "SameSite=Strict"
 	case http.SameSiteLaxMode:
 		e.SameSite = "SameSite=Lax"
+	case http.SameSiteNoneMode:
+		e.SameSite = "SameSite=None"
 	}
 
+	if err = checkRFC6265bis(&e, c, https, si); err != nil {
+		return e, false, err
+	}
 	return e, false, nil
 }
 
@@ -557,8 +696,8 @@ type serialized struct {
 }
 
 func (j *Jar) MarshalJSON() ([]byte, error) {
-	j.mu.Lock()
-	defer j.mu.Unlock()
+	j.mu.RLock()
+	defer j.mu.RUnlock()
 
 	return json.Marshal(&serialized{Entries: j.entries, NextSeqNum: j.nextSeqNum})
 }
@@ -571,18 +710,46 @@ func (j *Jar) UnmarshalJSON(data []byte) error {
 	if e := json.Unmarshal(data, &s); e != nil {
 		return e
 	}
-	j.entries = s.Entries
-	j.nextSeqNum = s.NextSeqNum
+	j.replaceEntries(s.Entries, s.NextSeqNum)
 	return nil
 }
 
+// replaceEntries replaces every cookie of the jar. It must be called with j.mu
+// held.
+func (j *Jar) replaceEntries(entries map[string]map[string]entry, nextSeqNum uint64) {
+	if entries == nil {
+		entries = make(map[string]map[string]entry)
+	}
+	for key := range j.entries {
+		j.markDirty(key)
+	}
+	j.entries = entries
+	j.nextSeqNum = nextSeqNum
+	j.addCount(-j.count)
+	for key, submap := range j.entries {
+		j.addCount(len(submap))
+		j.markDirty(key)
+	}
+}
+
+// Clone returns a copy of the jar. The copy has no storage.
 func (j *Jar) Clone() *Jar {
-	j.mu.Lock()
-	defer j.mu.Unlock()
+	j.mu.RLock()
+	defer j.mu.RUnlock()
 
+	entries := make(map[string]map[string]entry, len(j.entries))
+	for key, submap := range j.entries {
+		entries[key] = maps.Clone(submap)
+	}
 	return &Jar{
 		psList:     j.psList,
-		entries:    maps.Clone(j.entries),
+		entries:    entries,
+		count:      j.count,
 		nextSeqNum: j.nextSeqNum,
+		flushDelay: j.flushDelay,
+
+		maxPerDomain:  j.maxPerDomain,
+		maxCookies:    j.maxCookies,
+		maxCookieSize: j.maxCookieSize,
 	}
 }
@@ -0,0 +1,182 @@
+package cookiejar
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"github.com/k773/utils"
+	"time"
+)
+
+// Browser-like limits used by default, see Options.
+const (
+	DefaultMaxCookiesPerDomain = 180
+	DefaultMaxCookies          = 3000
+	DefaultMaxCookieSize       = 4096
+)
+
+var errCookieTooLarge = errors.New("cookiejar: cookie name and value exceed the size limit")
+
+// tooLarge reports whether e exceeds the cookie size limit.
+func (j *Jar) tooLarge(e *entry) bool {
+	return j.maxCookieSize > 0 && len(e.Name)+len(e.Value) > j.maxCookieSize
+}
+
+// limit returns the jar's limit for the option value: def for zero, no limit
+// (zero) for a negative value.
+func limit(v, def int) int {
+	switch {
+	case v == 0:
+		return def
+	case v < 0:
+		return 0
+	}
+	return v
+}
+
+// enforceLimits removes the expired cookies of the group key and evicts the
+// least recently used cookies while the group or the jar exceed the limits.
+// It must be called with j.mu held.
+func (j *Jar) enforceLimits(key string, now time.Time) {
+	if j.maxPerDomain <= 0 && j.maxCookies <= 0 {
+		return
+	}
+
+	if submap := j.entries[key]; j.maxPerDomain > 0 && len(submap) > j.maxPerDomain {
+		for id, e := range submap {
+			if e.expired(now) {
+				j.removeEntry(key, id, ChangeExpire, &e)
+			}
+		}
+		if n := len(j.entries[key]) - j.maxPerDomain; n > 0 {
+			for _, v := range j.lru(key, n) {
+				j.removeEntry(v.key, v.id, ChangeEvict, &v.e)
+			}
+		}
+	}
+
+	if j.maxCookies <= 0 || j.excess() <= 0 {
+		return
+	}
+	if j.removeExpired(now); j.excess() <= 0 {
+		return
+	}
+	for _, v := range j.lru("", min(j.excess(), j.count)) {
+		j.removeEntry(v.key, v.id, ChangeEvict, &v.e)
+	}
+}
+
+// addCount changes the amount of the entries by n. It must be called with
+// j.mu held.
+func (j *Jar) addCount(n int) {
+	j.count += n
+	if j.total != nil {
+		j.total.Add(int64(n))
+	}
+}
+
+// excess returns the amount of the cookies over MaxCookies; for a shard, the
+// cookies of the whole ShardedJar are counted. It must be called with j.mu
+// held.
+func (j *Jar) excess() int {
+	if j.total != nil {
+		return int(j.total.Load()) - j.maxCookies
+	}
+	return j.count - j.maxCookies
+}
+
+type lruEntry struct {
+	key, id string
+	e       entry
+}
+
+// lessRecentlyUsed reports whether a was used before b.
+func (a *lruEntry) lessRecentlyUsed(b *lruEntry) bool {
+	if ret := a.e.LastAccess.Compare(b.e.LastAccess); ret != 0 {
+		return ret < 0
+	}
+	return a.e.seqNum < b.e.seqNum
+}
+
+// lruHeap is a max-heap of the entries by their last use, so its root is the
+// most recently used of the kept entries.
+type lruHeap []lruEntry
+
+func (h lruHeap) Len() int           { return len(h) }
+func (h lruHeap) Less(i, k int) bool { return h[k].lessRecentlyUsed(&h[i]) }
+func (h lruHeap) Swap(i, k int)      { h[i], h[k] = h[k], h[i] }
+func (h *lruHeap) Push(x any)        { *h = append(*h, x.(lruEntry)) }
+func (h *lruHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// lru returns the n least recently used entries of the group key, or of every
+// group if key is empty. It takes O(m log n) for m entries. It must be called
+// with j.mu held.
+func (j *Jar) lru(key string, n int) []lruEntry {
+	h := make(lruHeap, 0, n)
+	add := func(k string, submap map[string]entry) {
+		for id, e := range submap {
+			v := lruEntry{key: k, id: id, e: e}
+			if len(h) < n {
+				heap.Push(&h, v)
+			} else if v.lessRecentlyUsed(&h[0]) {
+				h[0] = v
+				heap.Fix(&h, 0)
+			}
+		}
+	}
+	if key != "" {
+		add(key, j.entries[key])
+	} else {
+		for k, submap := range j.entries {
+			add(k, submap)
+		}
+	}
+	return h
+}
+
+// removeExpired removes every expired cookie and returns their amount. It
+// must be called with j.mu held.
+func (j *Jar) removeExpired(now time.Time) (n int) {
+	for key, submap := range j.entries {
+		for id, e := range submap {
+			if e.expired(now) {
+				j.removeEntry(key, id, ChangeExpire, &e)
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// StartPurge starts removing the expired cookies every interval in the
+// background, until StopPurge is called. A running purge is restarted.
+func (j *Jar) StartPurge(every time.Duration) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	j.mu.Lock()
+	if j.stopPurge != nil {
+		j.stopPurge()
+	}
+	j.stopPurge = cancel
+	j.mu.Unlock()
+
+	utils.RunForeverAsyncNoFirstTime(ctx, func() {
+		j.DeleteExpired()
+	}, every, false)
+}
+
+// StopPurge stops the background purge started by StartPurge.
+func (j *Jar) StopPurge() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.stopPurge != nil {
+		j.stopPurge()
+		j.stopPurge = nil
+	}
+}
@@ -0,0 +1,229 @@
+package cookiejar
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// LogStorage keeps the cookies of many jars (sessions) in a single
+// append-only file. Every save appends one record per changed group, and the
+// file is replayed when opened. Compact rewrites the file dropping the
+// overwritten records.
+type LogStorage struct {
+	path string
+
+	mu sync.Mutex
+	f  *os.File
+	// sessions is the replayed state: session -> eTLD+1 -> group.
+	sessions map[string]map[string]json.RawMessage
+}
+
+type logRecord struct {
+	Session string          `json:"s"`
+	Key     string          `json:"k"`
+	Group   json.RawMessage `json:"g,omitempty"`
+}
+
+// OpenLogStorage opens or creates the log file and replays it. A torn record
+// at the end of the file, left by a crash during a write, is truncated; any
+// other malformed record is an error.
+func OpenLogStorage(path string) (*LogStorage, error) {
+	l := &LogStorage{path: path, sessions: make(map[string]map[string]json.RawMessage)}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err = l.replay(f); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	l.f = f
+	return l, nil
+}
+
+// replay applies the records of the file and truncates it to the end of the
+// last complete record.
+func (l *LogStorage) replay(f *os.File) error {
+	r := bufio.NewReader(f)
+	var complete int64 // the end of the last complete record
+	for n := 1; ; n++ {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) == 0 {
+				return nil
+			}
+			// The record is torn, so the next append must not be glued to it.
+			return f.Truncate(complete)
+		} else if err != nil {
+			return err
+		}
+
+		var rec logRecord
+		if err = json.Unmarshal(line, &rec); err != nil {
+			return fmt.Errorf("cookiejar: %s: malformed record %d: %w", f.Name(), n, err)
+		}
+		l.apply(rec)
+		complete += int64(len(line))
+	}
+}
+
+// Session returns the Storage for the named jar.
+func (l *LogStorage) Session(name string) Storage {
+	return &logSession{l: l, name: name}
+}
+
+// Sessions returns the names of the sessions having cookies.
+func (l *LogStorage) Sessions() (names []string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for name := range l.sessions {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Compact rewrites the log keeping only the current state of every session.
+func (l *LogStorage) Compact() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	tmp, err := os.CreateTemp(filepath.Dir(l.path), filepath.Base(l.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	err = l.writeSnapshot(tmp)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if err2 := tmp.Close(); err == nil {
+		err = err2
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, l.path)
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+
+	_ = l.f.Close()
+	l.f, err = os.OpenFile(l.path, os.O_RDWR|os.O_APPEND, 0600)
+	return err
+}
+
+// writeSnapshot writes a record for every group. It must be called with l.mu
+// held.
+func (l *LogStorage) writeSnapshot(f *os.File) error {
+	w := bufio.NewWriter(f)
+	for session, groups := range l.sessions {
+		for key, group := range groups {
+			if err := writeLogRecord(w, logRecord{Session: session, Key: key, Group: group}); err != nil {
+				return err
+			}
+		}
+	}
+	return w.Flush()
+}
+
+func (l *LogStorage) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.f.Close()
+}
+
+func (l *LogStorage) load(session string) map[string][]byte {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	groups := make(map[string][]byte, len(l.sessions[session]))
+	for key, group := range l.sessions[session] {
+		groups[key] = group
+	}
+	return groups
+}
+
+// save appends the records of the groups and applies them once they are
+// synced. On a write error the file is truncated back, so a partially written
+// record is not followed by the next appends.
+func (l *LogStorage) save(session string, groups map[string][]byte) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	offset, err := l.f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	records := make([]logRecord, 0, len(groups))
+	w := bufio.NewWriter(l.f)
+	for key, group := range groups {
+		r := logRecord{Session: session, Key: key, Group: group}
+		if err = writeLogRecord(w, r); err != nil {
+			break
+		}
+		records = append(records, r)
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = l.f.Sync()
+	}
+	if err != nil {
+		_ = l.f.Truncate(offset)
+		return err
+	}
+
+	for _, r := range records {
+		l.apply(r)
+	}
+	return nil
+}
+
+// apply must be called with l.mu held or during the replay.
+func (l *LogStorage) apply(r logRecord) {
+	if r.Group == nil {
+		delete(l.sessions[r.Session], r.Key)
+		if len(l.sessions[r.Session]) == 0 {
+			delete(l.sessions, r.Session)
+		}
+		return
+	}
+	if l.sessions[r.Session] == nil {
+		l.sessions[r.Session] = make(map[string]json.RawMessage)
+	}
+	l.sessions[r.Session][r.Key] = r.Group
+}
+
+func writeLogRecord(w *bufio.Writer, r logRecord) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(data); err != nil {
+		return err
+	}
+	return w.WriteByte('\n')
+}
+
+type logSession struct {
+	l    *LogStorage
+	name string
+}
+
+func (s *logSession) Load() (map[string][]byte, error) {
+	return s.l.load(s.name), nil
+}
+
+func (s *logSession) Save(groups map[string][]byte) error {
+	return s.l.save(s.name, groups)
+}
@@ -0,0 +1,11 @@
+//go:build go1.23
+
+package cookiejar
+
+import "net/http"
+
+// partitioned reports whether the cookie has the Partitioned attribute, which
+// net/http parses since Go 1.23.
+func partitioned(c *http.Cookie) bool {
+	return c.Partitioned || unparsedPartitioned(c)
+}
@@ -0,0 +1,24 @@
+//go:build go1.23
+
+package cookiejar
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestParsedPartitioned(t *testing.T) {
+	resp := http.Response{Header: http.Header{"Set-Cookie": {"a=1; Secure; SameSite=None; Partitioned"}}}
+	cookies := resp.Cookies()
+	if len(cookies) != 1 || !cookies[0].Partitioned {
+		t.Fatalf("cookies = %v", cookies)
+	}
+
+	j := NewDefault()
+	u := &url.URL{Scheme: "https", Host: "widget.example.com", Path: "/"}
+	j.SetCookiesWithContext(u, cookies, SiteContext{TopLevel: &url.URL{Scheme: "https", Host: "shop.com"}})
+	if all := j.All(); len(all) != 1 || all[0].PartitionKey != "https://shop.com" {
+		t.Errorf("stored = %v", all)
+	}
+}
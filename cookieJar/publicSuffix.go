@@ -0,0 +1,14 @@
+package cookiejar
+
+import "golang.org/x/net/publicsuffix"
+
+// DefaultPublicSuffixList is the public suffix list compiled into
+// golang.org/x/net/publicsuffix; it is updated together with the module.
+var DefaultPublicSuffixList PublicSuffixList = publicsuffix.List
+
+// NewDefault returns a new cookie jar with the browser-like limits using
+// DefaultPublicSuffixList, so a server can not set cookies for a public suffix
+// or another site.
+func NewDefault() *Jar {
+	return MustNew(&Options{PublicSuffixList: DefaultPublicSuffixList})
+}
@@ -0,0 +1,61 @@
+package cookiejar
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func mustParseURL(t *testing.T, s string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestNewDefaultDomains(t *testing.T) {
+	tests := []struct {
+		name   string
+		url    string
+		domain string
+		stored bool
+	}{
+		{name: "host-only", url: "https://www.example.com/", stored: true},
+		{name: "own site", url: "https://www.example.co.uk/", domain: "example.co.uk", stored: true},
+		{name: "own host", url: "https://www.example.co.uk/", domain: "www.example.co.uk", stored: true},
+		{name: "public suffix", url: "https://www.example.co.uk/", domain: "co.uk"},
+		{name: "top-level domain", url: "https://www.example.com/", domain: "com"},
+		{name: "another site", url: "https://www.example.com/", domain: "evil.com"},
+		{name: "sibling subdomain", url: "https://www.example.com/", domain: "api.example.com"},
+		{name: "private suffix", url: "https://a.github.io/", domain: "github.io"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := NewDefault()
+			j.SetCookies(mustParseURL(t, tt.url), []*http.Cookie{{Name: "a", Value: "1", Domain: tt.domain}})
+
+			stored := len(j.All()) != 0
+			if stored != tt.stored {
+				t.Errorf("stored = %v, want %v", stored, tt.stored)
+			}
+		})
+	}
+}
+
+func TestNewDefaultNotSentCrossSite(t *testing.T) {
+	j := NewDefault()
+	j.SetCookies(mustParseURL(t, "https://www.example.co.uk/"), []*http.Cookie{{Name: "a", Value: "1", Domain: "example.co.uk"}})
+
+	for _, u := range []string{"https://example.co.uk/", "https://api.example.co.uk/"} {
+		if len(j.Cookies(mustParseURL(t, u))) != 1 {
+			t.Errorf("%s: cookie not sent", u)
+		}
+	}
+	for _, u := range []string{"https://other.co.uk/", "https://co.uk/", "https://example.com/"} {
+		if len(j.Cookies(mustParseURL(t, u))) != 0 {
+			t.Errorf("%s: cookie sent", u)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package cookiejar
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// SiteContext describes the browsing context a request is made in. It is used
+// to enforce the SameSite attribute and to select the partition of the
+// partitioned (CHIPS) cookies as specified by RFC 6265bis.
+type SiteContext struct {
+	// TopLevel is the URL of the top-level document (the one in the address
+	// bar). A nil value means the request itself is the top-level document.
+	TopLevel *url.URL
+	// Navigation marks a top-level navigation, e.g. following a link.
+	Navigation bool
+	// Method is the HTTP method of the request.
+	// Default: GET
+	Method string
+}
+
+// CookiesWithContext is like Cookies but also enforces the SameSite attribute
+// of the cookies and selects the partitioned cookies of the top-level site.
+func (j *Jar) CookiesWithContext(u *url.URL, sc SiteContext) []*http.Cookie {
+	return j.cookies(u, &sc, time.Now())
+}
+
+// SetCookiesWithContext is like SetCookies but also rejects the SameSite=Lax
+// and SameSite=Strict cookies set by cross-site subresources and stores the
+// partitioned cookies in the partition of the top-level site.
+func (j *Jar) SetCookiesWithContext(u *url.URL, cookies []*http.Cookie, sc SiteContext) {
+	j.setCookies(u, cookies, &sc, time.Now())
+}
+
+var (
+	errPrefixSecure    = errors.New("cookiejar: __Secure- cookie must be secure and set from a secure origin")
+	errPrefixHost      = errors.New("cookiejar: __Host- cookie must be secure, host-only, have path / and be set from a secure origin")
+	errInsecureOrigin  = errors.New("cookiejar: secure cookie set from an insecure origin")
+	errSameSiteNone    = errors.New("cookiejar: SameSite=None cookie must be secure")
+	errPartitioned     = errors.New("cookiejar: partitioned cookie must be secure")
+	errCrossSiteCookie = errors.New("cookiejar: SameSite cookie set by a cross-site request")
+)
+
+// site returns the schemeful site of the host: the scheme and the eTLD+1.
+func (j *Jar) site(scheme, host string) string {
+	return scheme + "://" + jarKey(host, j.psList)
+}
+
+// siteInfo is the SiteContext resolved for a request.
+type siteInfo struct {
+	crossSite  bool
+	navigation bool
+	safeMethod bool
+	// partitionKey is the site of the top-level document.
+	partitionKey string
+}
+
+// resolveSite resolves sc for the request to host over scheme. A nil sc is
+// the legacy context: a same-site top-level request.
+func (j *Jar) resolveSite(sc *SiteContext, scheme, host string) (si siteInfo) {
+	reqSite := j.site(scheme, host)
+	si.partitionKey, si.navigation, si.safeMethod = reqSite, true, true
+	if sc == nil {
+		return si
+	}
+
+	si.navigation = sc.Navigation
+	switch strings.ToUpper(sc.Method) {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+	default:
+		si.safeMethod = false
+	}
+	if sc.TopLevel != nil {
+		if topHost, err := canonicalHost(sc.TopLevel.Host); err == nil {
+			si.partitionKey = j.site(sc.TopLevel.Scheme, topHost)
+		}
+		si.crossSite = si.partitionKey != reqSite
+	}
+	return si
+}
+
+// sameSiteMode returns the effective SameSite mode of e; cookies without the
+// attribute are treated as Lax, like the contemporary browsers do.
+func (e *entry) sameSiteMode() http.SameSite {
+	switch e.SameSite {
+	case "SameSite=Strict":
+		return http.SameSiteStrictMode
+	case "SameSite=None":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
+
+// sameSiteAllowsSend reports whether the SameSite attribute and the partition
+// of e allow sending it in the site context.
+func (e *entry) sameSiteAllowsSend(si *siteInfo) bool {
+	if e.PartitionKey != "" && e.PartitionKey != si.partitionKey {
+		return false
+	}
+	if !si.crossSite {
+		return true
+	}
+	switch e.sameSiteMode() {
+	case http.SameSiteNoneMode:
+		return true
+	case http.SameSiteLaxMode:
+		return si.navigation && si.safeMethod
+	default:
+		return false
+	}
+}
+
+// checkRFC6265bis validates e, created from c received over https or http, in
+// the site context: the cookie prefixes, the Secure attribute requirements and
+// the SameSite restrictions of RFC 6265bis.
+func checkRFC6265bis(e *entry, c *http.Cookie, https bool, si *siteInfo) error {
+	if e.Secure && !https {
+		return errInsecureOrigin
+	}
+	if strings.HasPrefix(e.Name, "__Secure-") && !e.Secure {
+		return errPrefixSecure
+	}
+	if strings.HasPrefix(e.Name, "__Host-") && (!e.Secure || c.Domain != "" || e.Path != "/") {
+		return errPrefixHost
+	}
+	if e.sameSiteMode() == http.SameSiteNoneMode && !e.Secure {
+		return errSameSiteNone
+	}
+	if e.PartitionKey != "" && !e.Secure {
+		return errPartitioned
+	}
+	if si.crossSite && e.sameSiteMode() != http.SameSiteNoneMode && !si.navigation {
+		return errCrossSiteCookie
+	}
+	return nil
+}
+
+// unparsedPartitioned reports whether the Partitioned attribute is among the
+// ones net/http did not parse.
+func unparsedPartitioned(c *http.Cookie) bool {
+	for _, attr := range c.Unparsed {
+		if strings.EqualFold(strings.TrimSpace(attr), "Partitioned") {
+			return true
+		}
+	}
+	return false
+}
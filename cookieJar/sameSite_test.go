@@ -0,0 +1,258 @@
+package cookiejar
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func hasCookie(cookies []*http.Cookie, name string) bool {
+	for _, c := range cookies {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSetCookiesWithContext(t *testing.T) {
+	tests := []struct {
+		name   string
+		url    string
+		cookie http.Cookie
+		sc     SiteContext
+		stored bool
+	}{
+		{
+			name:   "strict same-site",
+			url:    "https://www.example.com/",
+			cookie: http.Cookie{Name: "a", Value: "1", SameSite: http.SameSiteStrictMode},
+			sc:     SiteContext{TopLevel: &url.URL{Scheme: "https", Host: "example.com"}},
+			stored: true,
+		},
+		{
+			name:   "strict cross-site subresource",
+			url:    "https://www.example.com/",
+			cookie: http.Cookie{Name: "a", Value: "1", SameSite: http.SameSiteStrictMode},
+			sc:     SiteContext{TopLevel: &url.URL{Scheme: "https", Host: "evil.com"}},
+		},
+		{
+			name:   "lax cross-site subresource",
+			url:    "https://www.example.com/",
+			cookie: http.Cookie{Name: "a", Value: "1", SameSite: http.SameSiteLaxMode},
+			sc:     SiteContext{TopLevel: &url.URL{Scheme: "https", Host: "evil.com"}},
+		},
+		{
+			name:   "default cross-site subresource",
+			url:    "https://www.example.com/",
+			cookie: http.Cookie{Name: "a", Value: "1"},
+			sc:     SiteContext{TopLevel: &url.URL{Scheme: "https", Host: "evil.com"}},
+		},
+		{
+			name:   "lax cross-site navigation",
+			url:    "https://www.example.com/",
+			cookie: http.Cookie{Name: "a", Value: "1", SameSite: http.SameSiteLaxMode},
+			sc:     SiteContext{TopLevel: &url.URL{Scheme: "https", Host: "evil.com"}, Navigation: true},
+			stored: true,
+		},
+		{
+			name:   "none secure cross-site subresource",
+			url:    "https://www.example.com/",
+			cookie: http.Cookie{Name: "a", Value: "1", SameSite: http.SameSiteNoneMode, Secure: true},
+			sc:     SiteContext{TopLevel: &url.URL{Scheme: "https", Host: "evil.com"}},
+			stored: true,
+		},
+		{
+			name:   "none not secure",
+			url:    "https://www.example.com/",
+			cookie: http.Cookie{Name: "a", Value: "1", SameSite: http.SameSiteNoneMode},
+		},
+		{
+			name:   "secure from http",
+			url:    "http://www.example.com/",
+			cookie: http.Cookie{Name: "a", Value: "1", Secure: true},
+		},
+		{
+			name:   "__Secure- valid",
+			url:    "https://www.example.com/",
+			cookie: http.Cookie{Name: "__Secure-a", Value: "1", Secure: true},
+			stored: true,
+		},
+		{
+			name:   "__Secure- not secure",
+			url:    "https://www.example.com/",
+			cookie: http.Cookie{Name: "__Secure-a", Value: "1"},
+		},
+		{
+			name:   "__Host- valid",
+			url:    "https://www.example.com/",
+			cookie: http.Cookie{Name: "__Host-a", Value: "1", Secure: true, Path: "/"},
+			stored: true,
+		},
+		{
+			name:   "__Host- with domain",
+			url:    "https://www.example.com/",
+			cookie: http.Cookie{Name: "__Host-a", Value: "1", Secure: true, Path: "/", Domain: "example.com"},
+		},
+		{
+			name:   "__Host- with path",
+			url:    "https://www.example.com/x/",
+			cookie: http.Cookie{Name: "__Host-a", Value: "1", Secure: true, Path: "/x"},
+		},
+		{
+			name:   "__Host- not secure",
+			url:    "https://www.example.com/",
+			cookie: http.Cookie{Name: "__Host-a", Value: "1", Path: "/"},
+		},
+		{
+			name:   "partitioned cross-site",
+			url:    "https://widget.example.com/",
+			cookie: http.Cookie{Name: "a", Value: "1", SameSite: http.SameSiteNoneMode, Secure: true, Unparsed: []string{"Partitioned"}},
+			sc:     SiteContext{TopLevel: &url.URL{Scheme: "https", Host: "shop.com"}},
+			stored: true,
+		},
+		{
+			name:   "partitioned not secure",
+			url:    "https://www.example.com/",
+			cookie: http.Cookie{Name: "a", Value: "1", Unparsed: []string{"Partitioned"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := NewDefault()
+			u := mustParseURL(t, tt.url)
+			j.SetCookiesWithContext(u, []*http.Cookie{&tt.cookie}, tt.sc)
+
+			stored := len(j.All()) != 0
+			if stored != tt.stored {
+				t.Errorf("stored = %v, want %v", stored, tt.stored)
+			}
+		})
+	}
+}
+
+func TestCookiesWithContext(t *testing.T) {
+	u := &url.URL{Scheme: "https", Host: "www.example.com", Path: "/"}
+	evil := &url.URL{Scheme: "https", Host: "evil.com"}
+
+	j := NewDefault()
+	j.SetCookies(u, []*http.Cookie{
+		{Name: "strict", Value: "1", SameSite: http.SameSiteStrictMode},
+		{Name: "lax", Value: "1", SameSite: http.SameSiteLaxMode},
+		{Name: "default", Value: "1"},
+		{Name: "none", Value: "1", SameSite: http.SameSiteNoneMode, Secure: true},
+	})
+
+	tests := []struct {
+		name string
+		sc   SiteContext
+		want map[string]bool
+	}{
+		{
+			name: "same-site",
+			sc:   SiteContext{TopLevel: &url.URL{Scheme: "https", Host: "example.com"}},
+			want: map[string]bool{"strict": true, "lax": true, "default": true, "none": true},
+		},
+		{
+			name: "cross-site navigation",
+			sc:   SiteContext{TopLevel: evil, Navigation: true},
+			want: map[string]bool{"lax": true, "default": true, "none": true},
+		},
+		{
+			name: "cross-site navigation post",
+			sc:   SiteContext{TopLevel: evil, Navigation: true, Method: http.MethodPost},
+			want: map[string]bool{"none": true},
+		},
+		{
+			name: "cross-site subresource",
+			sc:   SiteContext{TopLevel: evil},
+			want: map[string]bool{"none": true},
+		},
+		{
+			name: "cross-scheme subresource",
+			sc:   SiteContext{TopLevel: &url.URL{Scheme: "http", Host: "example.com"}},
+			want: map[string]bool{"none": true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cookies := j.CookiesWithContext(u, tt.sc)
+			for _, name := range []string{"strict", "lax", "default", "none"} {
+				if got := hasCookie(cookies, name); got != tt.want[name] {
+					t.Errorf("%s sent = %v, want %v", name, got, tt.want[name])
+				}
+			}
+		})
+	}
+}
+
+func TestPartitionedCookies(t *testing.T) {
+	u := &url.URL{Scheme: "https", Host: "widget.example.com", Path: "/"}
+	shop := SiteContext{TopLevel: &url.URL{Scheme: "https", Host: "www.shop.com"}}
+	news := SiteContext{TopLevel: &url.URL{Scheme: "https", Host: "news.org"}}
+
+	j := NewDefault()
+	j.SetCookiesWithContext(u, []*http.Cookie{
+		{Name: "chips", Value: "shop", SameSite: http.SameSiteNoneMode, Secure: true, Unparsed: []string{"Partitioned"}},
+	}, shop)
+	j.SetCookiesWithContext(u, []*http.Cookie{
+		{Name: "chips", Value: "news", SameSite: http.SameSiteNoneMode, Secure: true, Unparsed: []string{"Partitioned"}},
+	}, news)
+
+	all := j.All()
+	if len(all) != 2 {
+		t.Fatalf("stored %d cookies, want 2", len(all))
+	}
+	keys := map[string]bool{}
+	for _, c := range all {
+		keys[c.PartitionKey] = true
+	}
+	if !keys["https://shop.com"] || !keys["https://news.org"] {
+		t.Errorf("partition keys = %v", keys)
+	}
+
+	for sc, want := range map[*SiteContext]string{&shop: "shop", &news: "news"} {
+		cookies := j.CookiesWithContext(u, *sc)
+		if len(cookies) != 1 || cookies[0].Value != want {
+			t.Errorf("%s: cookies = %v, want %s", sc.TopLevel.Host, cookies, want)
+		}
+	}
+	other := SiteContext{TopLevel: &url.URL{Scheme: "https", Host: "other.net"}}
+	if cookies := j.CookiesWithContext(u, other); len(cookies) != 0 {
+		t.Errorf("other.net: cookies = %v, want none", cookies)
+	}
+
+	var buf strings.Builder
+	if err := j.ExportNetscape(&buf); err != nil {
+		t.Fatal(err)
+	}
+	netscape := NewDefault()
+	if n, err := netscape.ImportNetscape(strings.NewReader(buf.String())); err != nil || n != 2 {
+		t.Fatalf("ImportNetscape = %d, %v", n, err)
+	}
+	data, err := j.ExportBrowserJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	browser := NewDefault()
+	if n, err := browser.ImportBrowserJSON(data); err != nil || n != 2 {
+		t.Fatalf("ImportBrowserJSON = %d, %v", n, err)
+	}
+	// cookies.txt has no SameSite field, so only the partitions are compared.
+	for _, c := range netscape.All() {
+		if !keys[c.PartitionKey] {
+			t.Errorf("netscape: partition key %q", c.PartitionKey)
+		}
+	}
+	if cookies := browser.CookiesWithContext(u, shop); len(cookies) != 1 || cookies[0].Value != "shop" {
+		t.Errorf("browser: cookies = %v, want shop", cookies)
+	}
+
+	if !j.Delete("widget.example.com", "/", "chips") {
+		t.Fatal("Delete = false")
+	}
+	if all = j.All(); len(all) != 0 {
+		t.Errorf("after Delete: %v", all)
+	}
+}
@@ -0,0 +1,288 @@
+package cookiejar
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/k773/utils"
+	"hash/fnv"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultShards is used by NewSharded if shards is 0.
+const defaultShards = 32
+
+// ShardedJar is a cookie jar split into shards by the eTLD+1 of the cookies,
+// each one having its own lock, for the jars shared by many goroutines. Every
+// shard is a Jar, so a request only locks the shard of its host.
+type ShardedJar struct {
+	psList PublicSuffixList
+	shards []*Jar
+
+	// mu locks stopPurge.
+	mu        sync.Mutex
+	stopPurge func()
+}
+
+// NewSharded returns a new cookie jar having the given amount of shards. The
+// options are the ones of New, and the storage is shared by the shards. A zero
+// shards means 32.
+//
+// MaxCookies limits the cookies of all the shards together, but a shard
+// exceeding it only evicts its own least recently used cookies, as it does not
+// lock the other shards; so the evicted cookies may differ from the ones a Jar
+// would evict, and concurrent writes may evict a few cookies more than needed.
+func NewSharded(o *Options, shards int) (*ShardedJar, error) {
+	if shards <= 0 {
+		shards = defaultShards
+	}
+	var opts Options
+	if o != nil {
+		opts = *o
+	}
+	storage := opts.Storage
+	opts.Storage = nil
+
+	s := &ShardedJar{psList: opts.PublicSuffixList, shards: make([]*Jar, shards)}
+	total := new(atomic.Int64)
+	for i := range s.shards {
+		s.shards[i] = MustNew(&opts)
+		s.shards[i].storage = storage
+		s.shards[i].total = total
+	}
+	if storage == nil {
+		return s, nil
+	}
+
+	groups, err := storage.Load()
+	if err != nil {
+		return nil, err
+	}
+	split := make([]map[string][]byte, shards)
+	for key, data := range groups {
+		i := s.index(key)
+		if split[i] == nil {
+			split[i] = make(map[string][]byte)
+		}
+		split[i][key] = data
+	}
+	for i, shard := range s.shards {
+		if err = shard.loadGroups(split[i]); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func MustNewSharded(o *Options, shards int) *ShardedJar {
+	v, e := NewSharded(o, shards)
+	if e != nil {
+		panic(e)
+	}
+	return v
+}
+
+// index returns the index of the shard storing the group key.
+func (s *ShardedJar) index(key string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32() % uint32(len(s.shards)))
+}
+
+// shardOf returns the shard storing the cookies of host.
+func (s *ShardedJar) shardOf(host string) *Jar {
+	host, err := canonicalHost(host)
+	if err != nil {
+		// The shard rejects the host as well.
+		return s.shards[0]
+	}
+	return s.shards[s.index(jarKey(host, s.psList))]
+}
+
+// shardOfDomain is like shardOf for the domains accepted by Jar.Find.
+func (s *ShardedJar) shardOfDomain(domain string) *Jar {
+	domain, err := canonicalDomain(domain)
+	if err != nil {
+		return s.shards[0]
+	}
+	return s.shards[s.index(jarKey(domain, s.psList))]
+}
+
+// Cookies implements the Cookies method of the http.CookieJar interface.
+func (s *ShardedJar) Cookies(u *url.URL) []*http.Cookie {
+	return s.shardOf(u.Host).Cookies(u)
+}
+
+// SetCookies implements the SetCookies method of the http.CookieJar interface.
+func (s *ShardedJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	s.shardOf(u.Host).SetCookies(u, cookies)
+}
+
+// CookiesWithContext is the sharded version of Jar.CookiesWithContext.
+func (s *ShardedJar) CookiesWithContext(u *url.URL, sc SiteContext) []*http.Cookie {
+	return s.shardOf(u.Host).CookiesWithContext(u, sc)
+}
+
+// SetCookiesWithContext is the sharded version of Jar.SetCookiesWithContext.
+func (s *ShardedJar) SetCookiesWithContext(u *url.URL, cookies []*http.Cookie, sc SiteContext) {
+	s.shardOf(u.Host).SetCookiesWithContext(u, cookies, sc)
+}
+
+/*
+	Inspection
+*/
+
+// SetOnChange sets the hook of every shard, see Jar.SetOnChange. The hook may
+// be called concurrently by the different shards.
+func (s *ShardedJar) SetOnChange(f func(c Change)) {
+	for _, shard := range s.shards {
+		shard.SetOnChange(f)
+	}
+}
+
+// All returns every unexpired cookie ordered by domain, path and name.
+func (s *ShardedJar) All() []Cookie {
+	now := time.Now()
+
+	var all []entry
+	for _, shard := range s.shards {
+		shard.mu.RLock()
+		all = append(all, shard.sortedEntries()...)
+		shard.mu.RUnlock()
+	}
+	sort.Slice(all, func(i, k int) bool {
+		return all[i].id() < all[k].id()
+	})
+
+	var res []Cookie
+	for _, e := range all {
+		if !e.expired(now) {
+			res = append(res, e.cookie())
+		}
+	}
+	return res
+}
+
+// Find is the sharded version of Jar.Find.
+func (s *ShardedJar) Find(domain, name string) []Cookie {
+	return s.shardOfDomain(domain).Find(domain, name)
+}
+
+// Delete is the sharded version of Jar.Delete.
+func (s *ShardedJar) Delete(domain, path, name string) bool {
+	return s.shardOfDomain(domain).Delete(domain, path, name)
+}
+
+// DeleteExpired removes every expired cookie and returns their amount.
+func (s *ShardedJar) DeleteExpired() (n int) {
+	for _, shard := range s.shards {
+		n += shard.DeleteExpired()
+	}
+	return n
+}
+
+// Domains is the sharded version of Jar.Domains.
+func (s *ShardedJar) Domains() (keys []string) {
+	for _, shard := range s.shards {
+		keys = append(keys, shard.Domains()...)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Flush saves the changes of every shard, see Jar.Flush.
+func (s *ShardedJar) Flush() (err error) {
+	for _, shard := range s.shards {
+		if e := shard.Flush(); err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// StartPurge starts removing the expired cookies every interval in the
+// background, until StopPurge is called. A running purge is restarted.
+func (s *ShardedJar) StartPurge(every time.Duration) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s.mu.Lock()
+	if s.stopPurge != nil {
+		s.stopPurge()
+	}
+	s.stopPurge = cancel
+	s.mu.Unlock()
+
+	utils.RunForeverAsyncNoFirstTime(ctx, func() {
+		s.DeleteExpired()
+	}, every, false)
+}
+
+// StopPurge stops the background purge started by StartPurge.
+func (s *ShardedJar) StopPurge() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopPurge != nil {
+		s.stopPurge()
+		s.stopPurge = nil
+	}
+}
+
+/*
+	Serialization
+*/
+
+// MarshalJSON uses the format of Jar, so the sharded and the plain jars can be
+// restored from each other's output.
+func (s *ShardedJar) MarshalJSON() ([]byte, error) {
+	for _, shard := range s.shards {
+		shard.mu.RLock()
+		defer shard.mu.RUnlock()
+	}
+
+	res := serialized{Entries: make(map[string]map[string]entry)}
+	for _, shard := range s.shards {
+		for key, submap := range shard.entries {
+			res.Entries[key] = submap
+		}
+		res.NextSeqNum = max(res.NextSeqNum, shard.nextSeqNum)
+	}
+	return json.Marshal(&res)
+}
+
+func (s *ShardedJar) UnmarshalJSON(data []byte) error {
+	var res serialized
+	if e := json.Unmarshal(data, &res); e != nil {
+		return e
+	}
+
+	split := make([]map[string]map[string]entry, len(s.shards))
+	for i := range split {
+		split[i] = make(map[string]map[string]entry)
+	}
+	for key, submap := range res.Entries {
+		split[s.index(key)][key] = submap
+	}
+	for i, shard := range s.shards {
+		shard.mu.Lock()
+		shard.replaceEntries(split[i], res.NextSeqNum)
+		shard.mu.Unlock()
+	}
+	return nil
+}
+
+// Clone returns a copy of the jar. The copy has no storage.
+func (s *ShardedJar) Clone() *ShardedJar {
+	res := &ShardedJar{psList: s.psList, shards: make([]*Jar, len(s.shards))}
+	total := new(atomic.Int64)
+	for i, shard := range s.shards {
+		res.shards[i] = shard.Clone()
+		res.shards[i].total = total
+		total.Add(int64(res.shards[i].count))
+	}
+	return res
+}
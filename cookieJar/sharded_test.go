@@ -0,0 +1,81 @@
+package cookiejar
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"testing"
+)
+
+const benchHosts = 1000
+
+func benchmarkJar(b *testing.B, jar http.CookieJar) {
+	urls := make([]*url.URL, benchHosts)
+	for i := range urls {
+		urls[i] = &url.URL{Scheme: "https", Host: fmt.Sprintf("www.site%d.com", i), Path: "/"}
+	}
+	cookies := []*http.Cookie{{Name: "session", Value: "value", Path: "/"}}
+
+	var next atomic.Int64
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := int(next.Add(benchHosts / 8)); pb.Next(); i++ {
+			u := urls[i%benchHosts]
+			// One write per four reads, like a client receiving cookies
+			// on some of the responses.
+			if i%4 == 0 {
+				jar.SetCookies(u, cookies)
+			} else {
+				jar.Cookies(u)
+			}
+		}
+	})
+}
+
+func BenchmarkJar(b *testing.B) {
+	benchmarkJar(b, NewDefault())
+}
+
+func BenchmarkShardedJar(b *testing.B) {
+	benchmarkJar(b, MustNewSharded(&Options{PublicSuffixList: DefaultPublicSuffixList}, 0))
+}
+
+// cookieJar is the part of the Jar and ShardedJar API used by the tests.
+type cookieJar interface {
+	http.CookieJar
+	All() []Cookie
+}
+
+func TestShardedJarLimits(t *testing.T) {
+	tests := []struct {
+		name           string
+		opts           Options
+		hosts, perHost int
+		want           int
+	}{
+		{name: "one host under the domain limit", hosts: 1, perHost: 150, want: 150},
+		{name: "one host over the domain limit", hosts: 1, perHost: 200, want: DefaultMaxCookiesPerDomain},
+		{name: "many hosts under the limit", hosts: 200, perHost: 10, want: 2000},
+		{name: "many hosts over the limit", opts: Options{MaxCookies: 500}, hosts: 100, perHost: 10, want: 500},
+		{name: "no limits", opts: Options{MaxCookies: -1, MaxCookiesPerDomain: -1}, hosts: 20, perHost: 300, want: 6000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.PublicSuffixList = DefaultPublicSuffixList
+			jars := map[string]cookieJar{"Jar": MustNew(&tt.opts), "ShardedJar": MustNewSharded(&tt.opts, 0)}
+			for name, jar := range jars {
+				for i := 0; i < tt.hosts; i++ {
+					u := &url.URL{Scheme: "https", Host: fmt.Sprintf("www.site%d.com", i), Path: "/"}
+					for k := 0; k < tt.perHost; k++ {
+						jar.SetCookies(u, []*http.Cookie{{Name: fmt.Sprintf("c%d", k), Value: "v"}})
+					}
+				}
+				if got := len(jar.All()); got != tt.want {
+					t.Errorf("%s kept %d cookies, want %d", name, got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package cookiejar
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Storage persists the jar's cookies. The cookies are stored in groups keyed by
+// their eTLD+1, so only the changed groups have to be written.
+//
+// Implementations of Storage must be safe for concurrent use by multiple
+// goroutines.
+type Storage interface {
+	// Load returns every persisted group.
+	Load() (groups map[string][]byte, err error)
+	// Save persists the changed groups. A nil value means the group was
+	// removed.
+	Save(groups map[string][]byte) error
+}
+
+// defaultFlushDelay is used if Options.FlushDelay is 0.
+const defaultFlushDelay = time.Second
+
+// load fills the jar from its storage; called by New only.
+func (j *Jar) load() error {
+	groups, err := j.storage.Load()
+	if err != nil {
+		return err
+	}
+	return j.loadGroups(groups)
+}
+
+// loadGroups fills the jar with the persisted groups.
+func (j *Jar) loadGroups(groups map[string][]byte) error {
+	for key, data := range groups {
+		var submap map[string]entry
+		if err := json.Unmarshal(data, &submap); err != nil {
+			return err
+		}
+		if len(submap) == 0 {
+			continue
+		}
+		for id, e := range submap {
+			e.seqNum = j.nextSeqNum
+			j.nextSeqNum++
+			submap[id] = e
+		}
+		j.addCount(len(submap) - len(j.entries[key]))
+		j.entries[key] = submap
+	}
+	return nil
+}
+
+// markDirty schedules the group to be saved after the flush delay. It must be
+// called with j.mu held.
+func (j *Jar) markDirty(key string) {
+	if j.storage == nil {
+		return
+	}
+	if j.dirty == nil {
+		j.dirty = make(map[string]struct{})
+	}
+	j.dirty[key] = struct{}{}
+
+	if j.flushTimer == nil {
+		j.flushTimer = time.AfterFunc(j.flushDelay, func() {
+			_ = j.Flush()
+		})
+	}
+}
+
+// Flush immediately saves the changed groups to the storage. Groups which
+// could not be saved are retried during the next flush.
+// It does nothing if the jar has no storage.
+func (j *Jar) Flush() error {
+	if j.storage == nil {
+		return nil
+	}
+
+	// flushMu keeps the order of saves, so an older state of a group never
+	// overwrites a newer one.
+	j.flushMu.Lock()
+	defer j.flushMu.Unlock()
+
+	j.mu.Lock()
+	if j.flushTimer != nil {
+		j.flushTimer.Stop()
+		j.flushTimer = nil
+	}
+	var groups = make(map[string][]byte, len(j.dirty))
+	var err error
+	for key := range j.dirty {
+		if submap := j.entries[key]; len(submap) != 0 {
+			if groups[key], err = json.Marshal(submap); err != nil {
+				break
+			}
+		} else {
+			groups[key] = nil
+		}
+		delete(j.dirty, key)
+	}
+	j.mu.Unlock()
+
+	if err == nil {
+		if len(groups) == 0 {
+			return nil
+		}
+		err = j.storage.Save(groups)
+	}
+	if err != nil {
+		j.mu.Lock()
+		for key := range groups {
+			j.markDirty(key)
+		}
+		j.mu.Unlock()
+	}
+	return err
+}
@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// LimiterBackend keeps the state of the DistributedLimiters shared by several
+// processes. A Redis-like store can implement it using an optimistic
+// transaction (WATCH/MULTI/EXEC) or a script.
+type LimiterBackend interface {
+	// Update atomically replaces the state of the key by the result of f.
+	// The state is nil if the key has none. f may be called several times
+	// by the optimistic implementations.
+	Update(ctx context.Context, key string, f func(state []byte) ([]byte, error)) error
+}
+
+var errLimiterState = errors.New("rate limiter: malformed state")
+
+// DistributedLimiter is a token bucket limiter whose state is kept in a
+// LimiterBackend, so the limit is enforced across the processes using the same
+// backend and key. The processes must have synchronized clocks.
+type DistributedLimiter struct {
+	Backend LimiterBackend
+	Key     string
+
+	every time.Duration
+	burst int
+}
+
+// NewDistributedLimiter returns a limiter allowing an event every interval on
+// average and up to burst events at once, see NewTokenBucket.
+func NewDistributedLimiter(backend LimiterBackend, key string, every time.Duration, burst int) *DistributedLimiter {
+	return &DistributedLimiter{Backend: backend, Key: key, every: every, burst: max(burst, 1)}
+}
+
+// update runs f over the bucket stored in the backend.
+func (d *DistributedLimiter) update(ctx context.Context, f func(b *tokenBucket)) error {
+	return d.Backend.Update(ctx, d.Key, func(state []byte) ([]byte, error) {
+		b := tokenBucket{every: d.every, burst: d.burst}
+		switch len(state) {
+		case 0:
+		case 8:
+			b.tat = time.Unix(0, int64(binary.BigEndian.Uint64(state)))
+		default:
+			return nil, errLimiterState
+		}
+		f(&b)
+		return binary.BigEndian.AppendUint64(nil, uint64(b.tat.UnixNano())), nil
+	})
+}
+
+// reserve reserves n events if they are allowed within maxDelay. never is true
+// if they can never be allowed.
+func (d *DistributedLimiter) reserve(ctx context.Context, n int, maxDelay time.Duration) (r *Reservation, never bool, e error) {
+	r = new(Reservation)
+	e = d.update(ctx, func(b *tokenBucket) {
+		now := time.Now()
+		deadline := deadlineAfter(now, maxDelay)
+		at, ok := b.reserve(now, n, deadline)
+		*r, never = Reservation{}, !ok
+		if ok && !at.After(deadline) {
+			*r = Reservation{ok: true, at: at, cancel: func() {
+				_ = d.update(context.Background(), func(b *tokenBucket) {
+					b.cancel(time.Now(), at, n)
+				})
+			}}
+		}
+	})
+	if e != nil {
+		return &Reservation{}, false, e
+	}
+	return
+}
+
+func (d *DistributedLimiter) Wait(ctx context.Context) error {
+	r, never, e := d.reserve(ctx, 1, maxDelay(ctx))
+	switch {
+	case e != nil:
+		return e
+	case !r.OK():
+		return If(never, ErrLimiterExceeded, ErrLimiterDeadline)
+	}
+	return r.Wait(ctx)
+}
+
+// TryAcquire reports false if the backend fails.
+func (d *DistributedLimiter) TryAcquire() bool {
+	r, _, _ := d.reserve(context.Background(), 1, 0)
+	return r.OK()
+}
+
+// Reserve returns a reservation which is not OK if the backend fails.
+func (d *DistributedLimiter) Reserve(n int) *Reservation {
+	r, _, _ := d.reserve(context.Background(), n, math.MaxInt64)
+	return r
+}
+
+// Throttle throttles every process using the limiter. The backend errors are
+// ignored.
+func (d *DistributedLimiter) Throttle(duration time.Duration) {
+	until := time.Now().Add(duration)
+	_ = d.update(context.Background(), func(b *tokenBucket) {
+		b.throttle(until)
+	})
+}
+
+/*
+	File backend
+*/
+
+// FileLimiterBackend keeps the states in the files of Dir, locked with flock
+// (LockFileEx on windows), for the processes of a single machine.
+type FileLimiterBackend struct {
+	Dir string
+}
+
+func NewFileLimiterBackend(dir string) (*FileLimiterBackend, error) {
+	return &FileLimiterBackend{Dir: dir}, os.MkdirAll(dir, 0755)
+}
+
+// Update locks the file of the key for the time of f. The lock can not be
+// interrupted, ctx is only checked before taking it.
+func (b *FileLimiterBackend) Update(ctx context.Context, key string, f func(state []byte) ([]byte, error)) (e error) {
+	if e = ctx.Err(); e != nil {
+		return
+	}
+	file, e := os.OpenFile(filepath.Join(b.Dir, url.QueryEscape(key)+".limiter"), os.O_RDWR|os.O_CREATE, 0644)
+	if e != nil {
+		return
+	}
+	defer file.Close()
+
+	if e = lockFile(file); e != nil {
+		return
+	}
+	defer unlockFile(file)
+
+	var state, newState []byte
+	if state, e = io.ReadAll(file); e == nil {
+		if newState, e = f(state); e == nil {
+			if _, e = file.WriteAt(newState, 0); e == nil {
+				e = file.Truncate(int64(len(newState)))
+			}
+		}
+	}
+	return
+}
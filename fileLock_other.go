@@ -0,0 +1,18 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly && !windows
+
+package utils
+
+import (
+	"errors"
+	"os"
+)
+
+// The file locking is not available, so FileLimiterBackend can not be used.
+
+func lockFile(f *os.File) error {
+	return errors.ErrUnsupported
+}
+
+func unlockFile(f *os.File) error {
+	return errors.ErrUnsupported
+}
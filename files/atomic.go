@@ -0,0 +1,34 @@
+package files
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// WriteFileAtomic writes data to a temporary file in the same directory and renames it to path, so the readers
+// never see a partially written file.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) (e error) {
+	f, e := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if e != nil {
+		return
+	}
+	defer func() {
+		if e != nil {
+			_ = os.Remove(f.Name())
+		}
+	}()
+
+	if _, e = f.Write(data); e == nil {
+		e = f.Sync()
+	}
+	if e2 := f.Close(); e == nil {
+		e = e2
+	}
+	if e == nil {
+		e = os.Chmod(f.Name(), perm)
+	}
+	if e == nil {
+		e = os.Rename(f.Name(), path)
+	}
+	return
+}
@@ -13,6 +13,7 @@ require (
 	golang.org/x/crypto v0.23.0
 	golang.org/x/exp v0.0.0-20220613132600-b0d781184e0d
 	golang.org/x/net v0.25.0
+	golang.org/x/sys v0.20.0
 	golang.org/x/text v0.15.0
 )
 
@@ -21,5 +22,4 @@ require (
 	github.com/klauspost/compress v1.15.0 // indirect
 	github.com/philhofer/fwd v1.1.2 // indirect
 	github.com/valyala/bytebufferpool v1.0.0 // indirect
-	golang.org/x/sys v0.20.0 // indirect
 )
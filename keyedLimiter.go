@@ -0,0 +1,174 @@
+package utils
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// KeyedLimiter keeps a Limiter per key, e.g. per target host, API key or
+// proxy. The limiters are created on the first use of their key, and Run
+// evicts the ones idle for longer than IdleTimeout.
+type KeyedLimiter[K comparable] struct {
+	// NewLimiter creates the limiter of a key having no override.
+	NewLimiter func(key K) Limiter
+	// IdleTimeout is the time a limiter is kept after its last use and the
+	// end of its reservations and throttling.
+	// Default: 10m
+	IdleTimeout time.Duration
+
+	mu        sync.Mutex
+	limiters  map[K]*keyedLimiterEntry
+	overrides map[K]func() Limiter
+}
+
+type keyedLimiterEntry struct {
+	l Limiter
+	// busyUntil is the latest of the last use, the reservations and the end
+	// of the throttling.
+	busyUntil time.Time
+	// waiters is the amount of the Wait calls in progress; the entry is not
+	// evicted while it is positive.
+	waiters int
+}
+
+func NewKeyedLimiter[K comparable](newLimiter func(key K) Limiter) *KeyedLimiter[K] {
+	return &KeyedLimiter[K]{
+		NewLimiter:  newLimiter,
+		IdleTimeout: 10 * time.Minute,
+		limiters:    make(map[K]*keyedLimiterEntry),
+		overrides:   make(map[K]func() Limiter),
+	}
+}
+
+// use returns the limiter of the key, creating it if needed, and marks it busy
+// until the time.
+func (k *KeyedLimiter[K]) use(key K, until time.Time) Limiter {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	return k.entry(key, until).l
+}
+
+// entry returns the entry of the key, creating it if needed, and marks it busy
+// until the time. It must be called with k.mu held.
+func (k *KeyedLimiter[K]) entry(key K, until time.Time) *keyedLimiterEntry {
+	if k.limiters == nil {
+		k.limiters = make(map[K]*keyedLimiterEntry)
+	}
+	e := k.limiters[key]
+	if e == nil {
+		e = new(keyedLimiterEntry)
+		if override := k.overrides[key]; override != nil {
+			e.l = override()
+		} else {
+			e.l = k.NewLimiter(key)
+		}
+		k.limiters[key] = e
+	}
+	e.busyUntil = maxTime(e.busyUntil, until)
+	return e
+}
+
+// Get returns the limiter of the key. A limiter kept by the caller may be
+// evicted while it is idle, so it is better to use the methods of KeyedLimiter.
+func (k *KeyedLimiter[K]) Get(key K) Limiter {
+	return k.use(key, time.Now())
+}
+
+// Wait waits for the limiter of the key, which is not evicted until the call
+// returns.
+func (k *KeyedLimiter[K]) Wait(ctx context.Context, key K) error {
+	k.mu.Lock()
+	e := k.entry(key, time.Now())
+	e.waiters++
+	k.mu.Unlock()
+
+	defer func() {
+		k.mu.Lock()
+		e.waiters--
+		e.busyUntil = maxTime(e.busyUntil, time.Now())
+		k.mu.Unlock()
+	}()
+	return e.l.Wait(ctx)
+}
+
+func (k *KeyedLimiter[K]) TryAcquire(key K) bool {
+	return k.use(key, time.Now()).TryAcquire()
+}
+
+func (k *KeyedLimiter[K]) Reserve(key K, n int) (r *Reservation) {
+	r = k.use(key, time.Now()).Reserve(n)
+	if r.OK() {
+		k.use(key, r.at)
+	}
+	return r
+}
+
+// Throttle throttles only the limiter of the key, e.g. after the host replied
+// with HTTP 429.
+func (k *KeyedLimiter[K]) Throttle(key K, d time.Duration) {
+	k.use(key, time.Now().Add(d)).Throttle(d)
+}
+
+// SetOverride sets the constructor of the key's limiter, used instead of
+// NewLimiter. The current limiter of the key is replaced.
+func (k *KeyedLimiter[K]) SetOverride(key K, newLimiter func() Limiter) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.overrides == nil {
+		k.overrides = make(map[K]func() Limiter)
+	}
+	k.overrides[key] = newLimiter
+	delete(k.limiters, key)
+}
+
+// RemoveOverride makes the key use NewLimiter again. The current limiter of the
+// key is replaced.
+func (k *KeyedLimiter[K]) RemoveOverride(key K) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if _, ok := k.overrides[key]; ok {
+		delete(k.overrides, key)
+		delete(k.limiters, key)
+	}
+}
+
+// Len returns the amount of the kept limiters.
+func (k *KeyedLimiter[K]) Len() int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	return len(k.limiters)
+}
+
+// Run evicts the idle limiters every period until ctx is done.
+func (k *KeyedLimiter[K]) Run(ctx context.Context, period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for ctx.Err() == nil {
+		select {
+		case <-ctx.Done():
+		case t := <-ticker.C:
+			k.evictIdle(t)
+		}
+	}
+}
+
+func (k *KeyedLimiter[K]) evictIdle(now time.Time) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	idleTimeout := k.IdleTimeout
+	if idleTimeout <= 0 {
+		idleTimeout = 10 * time.Minute
+	}
+	for key, e := range k.limiters {
+		if e.waiters == 0 && now.Sub(e.busyUntil) > idleTimeout {
+			delete(k.limiters, key)
+		}
+	}
+}
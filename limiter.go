@@ -0,0 +1,340 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limiter is the common interface of the rate limiters.
+type Limiter interface {
+	// Wait blocks until an event is allowed or ctx is done.
+	Wait(ctx context.Context) error
+	// TryAcquire takes an event if it is allowed now, without waiting.
+	TryAcquire() bool
+	// Reserve reserves n events; the caller has to wait for
+	// Reservation.Delay before using them, or cancel the reservation.
+	Reserve(n int) *Reservation
+	// Throttle blocks the events for the duration, e.g. after the server
+	// reported too many requests.
+	Throttle(d time.Duration)
+}
+
+var (
+	ErrLimiterDeadline = errors.New("rate limiter: the event would be allowed after the context deadline")
+	ErrLimiterExceeded = errors.New("rate limiter: the amount of events can never be allowed")
+)
+
+// Reservation is the result of Limiter.Reserve.
+type Reservation struct {
+	ok     bool
+	at     time.Time
+	cancel func()
+}
+
+// OK reports whether the events were reserved; a limiter can not reserve more
+// events than its burst, capacity or window limit.
+func (r *Reservation) OK() bool {
+	return r.ok
+}
+
+// Delay returns the time to wait before using the reserved events. It is
+// math.MaxInt64 if the reservation is not OK.
+func (r *Reservation) Delay() time.Duration {
+	if !r.ok {
+		return math.MaxInt64
+	}
+	return max(time.Until(r.at), 0)
+}
+
+// Cancel returns the reserved events to the limiter as far as possible.
+func (r *Reservation) Cancel() {
+	if r.ok && r.cancel != nil {
+		r.cancel()
+		r.cancel = nil
+	}
+}
+
+// Wait waits for the delay of the reservation. The reservation is cancelled if
+// ctx is done first.
+func (r *Reservation) Wait(ctx context.Context) (e error) {
+	if !r.ok {
+		return ErrLimiterExceeded
+	}
+	if e = SleepWithContext(ctx, r.Delay()); e != nil {
+		r.Cancel()
+	}
+	return
+}
+
+/*
+	Limiter implementations
+*/
+
+// limiterCore is a rate limiting algorithm; it is used by limiter with the lock
+// held.
+type limiterCore interface {
+	// reserve reserves n events at the returned time, if it is not after the
+	// deadline. ok is false if n events can never be allowed; the time is
+	// after the deadline if they are not allowed by it.
+	reserve(now time.Time, n int, deadline time.Time) (at time.Time, ok bool)
+	// cancel returns n events reserved at the time.
+	cancel(now, at time.Time, n int)
+	// throttle disallows the events until the time.
+	throttle(until time.Time)
+}
+
+type limiter struct {
+	mu   sync.Mutex
+	core limiterCore
+}
+
+// farFuture is the deadline of the reservations that can wait forever.
+var farFuture = time.Unix(1<<62, 0)
+
+// maxDelay returns the time left until the deadline of ctx.
+func maxDelay(ctx context.Context) time.Duration {
+	if deadline, ok := ctx.Deadline(); ok {
+		return time.Until(deadline)
+	}
+	return math.MaxInt64
+}
+
+// deadlineAfter returns now+d, or farFuture if the sum overflows.
+func deadlineAfter(now time.Time, d time.Duration) time.Time {
+	if d == math.MaxInt64 {
+		return farFuture
+	}
+	return now.Add(d)
+}
+
+// reserve reserves n events if they are allowed within maxDelay. never is true
+// if they can never be allowed.
+func (l *limiter) reserve(n int, maxDelay time.Duration) (r *Reservation, never bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	deadline := deadlineAfter(now, maxDelay)
+	at, ok := l.core.reserve(now, n, deadline)
+	if !ok || at.After(deadline) {
+		return &Reservation{}, !ok
+	}
+	return &Reservation{ok: true, at: at, cancel: func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.core.cancel(time.Now(), at, n)
+	}}, false
+}
+
+func (l *limiter) Wait(ctx context.Context) error {
+	r, never := l.reserve(1, maxDelay(ctx))
+	if !r.OK() {
+		return If(never, ErrLimiterExceeded, ErrLimiterDeadline)
+	}
+	return r.Wait(ctx)
+}
+
+func (l *limiter) TryAcquire() bool {
+	r, _ := l.reserve(1, 0)
+	return r.OK()
+}
+
+func (l *limiter) Reserve(n int) *Reservation {
+	r, _ := l.reserve(n, math.MaxInt64)
+	return r
+}
+
+func (l *limiter) Throttle(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.core.throttle(time.Now().Add(d))
+}
+
+// NewTokenBucket returns a limiter allowing an event every interval on average
+// and up to burst events at once.
+func NewTokenBucket(every time.Duration, burst int) Limiter {
+	return &limiter{core: &tokenBucket{every: every, burst: max(burst, 1)}}
+}
+
+// tokenBucket is implemented as GCRA: tat is the theoretical arrival time of
+// the next event, with the bucket being full when tat is not after now.
+type tokenBucket struct {
+	every time.Duration
+	burst int
+	tat   time.Time
+}
+
+func (b *tokenBucket) reserve(now time.Time, n int, deadline time.Time) (at time.Time, ok bool) {
+	if n > b.burst {
+		return at, false
+	}
+	tat := maxTime(b.tat, now).Add(time.Duration(n) * b.every)
+	at = maxTime(tat.Add(-time.Duration(b.burst)*b.every), now)
+	if !at.After(deadline) {
+		b.tat = tat
+	}
+	return at, true
+}
+
+func (b *tokenBucket) cancel(now, at time.Time, n int) {
+	b.tat = maxTime(b.tat.Add(-time.Duration(n)*b.every), now)
+}
+
+func (b *tokenBucket) throttle(until time.Time) {
+	// The bucket has a single token at the time.
+	b.tat = maxTime(b.tat, until.Add(time.Duration(b.burst-1)*b.every))
+}
+
+// NewLeakyBucket returns a limiter releasing the events one by one every
+// interval, without bursts. At most capacity events may wait; the reservations
+// over the capacity are not OK and TryAcquire fails.
+func NewLeakyBucket(every time.Duration, capacity int) Limiter {
+	return &limiter{core: &leakyBucket{every: every, capacity: max(capacity, 1)}}
+}
+
+type leakyBucket struct {
+	every    time.Duration
+	capacity int
+	// next is the time of the next release.
+	next time.Time
+}
+
+func (b *leakyBucket) reserve(now time.Time, n int, deadline time.Time) (at time.Time, ok bool) {
+	if n > b.capacity {
+		return at, false
+	}
+	at = maxTime(b.next, now).Add(time.Duration(n-1) * b.every)
+	if at.Sub(now) >= time.Duration(b.capacity)*b.every {
+		// The bucket is full for now, so no deadline is met.
+		return deadline.Add(time.Nanosecond), true
+	}
+	if !at.After(deadline) {
+		b.next = at.Add(b.every)
+	}
+	return at, true
+}
+
+func (b *leakyBucket) cancel(now, at time.Time, n int) {
+	b.next = maxTime(b.next.Add(-time.Duration(n)*b.every), now)
+}
+
+func (b *leakyBucket) throttle(until time.Time) {
+	b.next = maxTime(b.next, until)
+}
+
+// NewFixedWindow returns a limiter allowing limit events per window; the
+// windows are aligned to the multiples of the window duration.
+func NewFixedWindow(window time.Duration, limit int) Limiter {
+	return &limiter{core: &fixedWindow{window: window, limit: max(limit, 1)}}
+}
+
+type fixedWindow struct {
+	window time.Duration
+	limit  int
+	// start is the start of the latest window having reservations, count is
+	// the amount of them.
+	start time.Time
+	count int
+	// until is the end of the throttling.
+	until time.Time
+}
+
+func (w *fixedWindow) reserve(now time.Time, n int, deadline time.Time) (at time.Time, ok bool) {
+	if n > w.limit {
+		return at, false
+	}
+	start, count := w.start, w.count
+	if current := now.Truncate(w.window); start.Before(current) {
+		start, count = current, 0
+	}
+	if count+n > w.limit {
+		start, count = start.Add(w.window), 0
+	}
+	at = maxTime(maxTime(start, now), w.until)
+	if !at.After(deadline) {
+		w.start, w.count = start, count+n
+	}
+	return at, true
+}
+
+func (w *fixedWindow) cancel(now, at time.Time, n int) {
+	if !at.Before(w.start) && at.Before(w.start.Add(w.window)) {
+		w.count = max(w.count-n, 0)
+	}
+}
+
+func (w *fixedWindow) throttle(until time.Time) {
+	w.until = maxTime(w.until, until)
+	if start := until.Truncate(w.window); w.start.Before(start) {
+		w.start, w.count = start, 0
+	}
+}
+
+// NewSlidingWindow returns a limiter allowing limit events during any window.
+func NewSlidingWindow(window time.Duration, limit int) Limiter {
+	limit = max(limit, 1)
+	return &limiter{core: &slidingWindow{window: window, limit: limit, log: make([]time.Time, 0, limit)}}
+}
+
+type slidingWindow struct {
+	window time.Duration
+	limit  int
+	// log is the sorted times of the latest (at most limit) events.
+	log []time.Time
+}
+
+func (w *slidingWindow) reserve(now time.Time, n int, deadline time.Time) (at time.Time, ok bool) {
+	if n > w.limit {
+		return at, false
+	}
+	at = now
+	if k := len(w.log) + n - w.limit; k > 0 {
+		// The event k-1 has to leave the window before the n events
+		// enter it.
+		at = maxTime(at, w.log[k-1].Add(w.window))
+	}
+	if len(w.log) > 0 {
+		at = maxTime(at, w.log[len(w.log)-1])
+	}
+	if at.After(deadline) {
+		return at, true
+	}
+	for i := 0; i < n; i++ {
+		w.log = append(w.log, at)
+	}
+	if k := len(w.log) - w.limit; k > 0 {
+		w.log = append(w.log[:0], w.log[k:]...)
+	}
+	return at, true
+}
+
+func (w *slidingWindow) cancel(now, at time.Time, n int) {
+	for i := len(w.log) - 1; i >= 0 && n > 0; i-- {
+		if w.log[i].Equal(at) {
+			w.log = append(w.log[:i], w.log[i+1:]...)
+			n--
+		}
+	}
+}
+
+func (w *slidingWindow) throttle(until time.Time) {
+	// Fill the window ending at the time.
+	start := until.Add(-w.window)
+	for i := range w.log {
+		w.log[i] = maxTime(w.log[i], start)
+	}
+	for len(w.log) < w.limit {
+		w.log = append([]time.Time{start}, w.log...)
+	}
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
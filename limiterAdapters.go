@@ -0,0 +1,233 @@
+package utils
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+/*
+	Adapters of the legacy rate limiters to Limiter
+*/
+
+// Limiter returns r as a Limiter. A ticker can not be reserved, so the
+// reservations are never OK.
+func (r *RateLimiter) Limiter() Limiter {
+	return &tickerLimiter{r: r}
+}
+
+type tickerLimiter struct {
+	r *RateLimiter
+
+	mu             sync.Mutex
+	throttledUntil time.Time
+}
+
+func (t *tickerLimiter) Wait(ctx context.Context) (e error) {
+	if e = SleepWithContext(ctx, t.throttled()); e == nil {
+		select {
+		case <-t.r.t.C:
+		case <-ctx.Done():
+			e = ctx.Err()
+		}
+	}
+	return
+}
+
+func (t *tickerLimiter) TryAcquire() bool {
+	if t.throttled() > 0 {
+		return false
+	}
+	select {
+	case <-t.r.t.C:
+		return true
+	default:
+		return false
+	}
+}
+
+func (t *tickerLimiter) Reserve(n int) *Reservation {
+	return &Reservation{}
+}
+
+func (t *tickerLimiter) Throttle(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.throttledUntil = maxTime(t.throttledUntil, time.Now().Add(d))
+}
+
+func (t *tickerLimiter) throttled() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return max(time.Until(t.throttledUntil), 0)
+}
+
+// Limiter returns r as a Limiter. The reservations are never OK, and Throttle
+// is Trigger: the duration is rounded to the next reset.
+func (r *RateLimiterV2) Limiter() Limiter {
+	return rateLimiterV2Adapter{r: r}
+}
+
+type rateLimiterV2Adapter struct {
+	r *RateLimiterV2
+}
+
+func (a rateLimiterV2Adapter) Wait(ctx context.Context) error {
+	stop := context.AfterFunc(ctx, func() {
+		a.r.cond.L.Lock()
+		a.r.cond.Broadcast()
+		a.r.cond.L.Unlock()
+	})
+	defer stop()
+
+	a.r.cond.L.Lock()
+	for a.r.triggered && ctx.Err() == nil {
+		a.r.cond.Wait()
+	}
+	a.r.cond.L.Unlock()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	select {
+	case a.r.wait <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (a rateLimiterV2Adapter) TryAcquire() bool {
+	a.r.cond.L.Lock()
+	triggered := a.r.triggered
+	a.r.cond.L.Unlock()
+	if triggered {
+		return false
+	}
+
+	select {
+	case a.r.wait <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (a rateLimiterV2Adapter) Reserve(n int) *Reservation {
+	return &Reservation{}
+}
+
+func (a rateLimiterV2Adapter) Throttle(time.Duration) {
+	a.r.Trigger()
+}
+
+// Limiter returns r as a Limiter. Wait of the Limiter does not block the Wait
+// of r, and the reservations can not be cancelled.
+func (r *RateLimiterV3) Limiter() Limiter {
+	return &lastReleaseLimiter{mu: &r.l, last: &r.lastRequest, every: r.b}
+}
+
+// Limiter returns r as a Limiter releasing the events every sinceLastRelease.
+// Wait of the Limiter does not block the Wait of r, and the reservations can
+// not be cancelled.
+func (r *RateLimiterV4) Limiter(sinceLastRelease time.Duration) Limiter {
+	return &lastReleaseLimiter{mu: &r.l, last: &r.lastRequest, every: sinceLastRelease}
+}
+
+// lastReleaseLimiter implements Limiter over the state of RateLimiterV3 and
+// RateLimiterV4.
+type lastReleaseLimiter struct {
+	mu    *sync.Mutex
+	last  *time.Time
+	every time.Duration
+}
+
+// reserve reserves n events if the last one is allowed within maxDelay. A zero
+// maxDelay does not wait for the lock, which the Wait of RateLimiterV3 and
+// RateLimiterV4 holds while sleeping.
+func (a *lastReleaseLimiter) reserve(n int, maxDelay time.Duration) *Reservation {
+	if maxDelay > 0 {
+		a.mu.Lock()
+	} else if !a.mu.TryLock() {
+		return &Reservation{}
+	}
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	at := now
+	if !a.last.IsZero() {
+		at = maxTime(at, a.last.Add(a.every))
+	}
+	at = at.Add(time.Duration(max(n, 1)-1) * a.every)
+	if at.After(deadlineAfter(now, maxDelay)) {
+		return &Reservation{}
+	}
+	*a.last = at
+	return &Reservation{ok: true, at: at}
+}
+
+func (a *lastReleaseLimiter) Wait(ctx context.Context) error {
+	if r := a.reserve(1, maxDelay(ctx)); r.OK() {
+		return r.Wait(ctx)
+	}
+	return ErrLimiterDeadline
+}
+
+func (a *lastReleaseLimiter) TryAcquire() bool {
+	return a.reserve(1, 0).OK()
+}
+
+func (a *lastReleaseLimiter) Reserve(n int) *Reservation {
+	return a.reserve(n, math.MaxInt64)
+}
+
+func (a *lastReleaseLimiter) Throttle(d time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// The next event is released at now+d.
+	*a.last = maxTime(*a.last, time.Now().Add(d-a.every))
+}
+
+// Limiter returns r as a Limiter releasing the events every sinceLastRelease.
+func (r *RateLimiterV5) Limiter(sinceLastRelease time.Duration) Limiter {
+	return &RateLimiterV6{v5: r, rateLimit: sinceLastRelease}
+}
+
+// reserve reserves n events sinceLastRelease apart, if the last one is allowed
+// within maxDelay. A zero maxDelay does not wait for the lock, which Wait holds
+// while sleeping.
+func (r *RateLimiterV5) reserve(sinceLastRelease time.Duration, n int, maxDelay time.Duration) *Reservation {
+	if maxDelay > 0 {
+		r.l.Lock()
+	} else if !r.l.TryLock() {
+		return &Reservation{}
+	}
+	defer r.l.Unlock()
+
+	now := time.Now()
+	at := maxTime(now, r.throttledUntil)
+	if !r.lastRequest.IsZero() {
+		at = maxTime(at, r.lastRequest.Add(sinceLastRelease))
+	}
+	at = at.Add(time.Duration(max(n, 1)-1) * sinceLastRelease)
+	if at.After(deadlineAfter(now, maxDelay)) {
+		return &Reservation{}
+	}
+	r.lastRequest = at
+	return &Reservation{ok: true, at: at}
+}
+
+// TryAcquire takes an event if it is allowed now. RateLimiterV6 implements
+// Limiter.
+func (r *RateLimiterV6) TryAcquire() bool {
+	return r.v5.reserve(r.rateLimit, 1, 0).OK()
+}
+
+// Reserve reserves n events; the reservation can not be cancelled.
+func (r *RateLimiterV6) Reserve(n int) *Reservation {
+	return r.v5.reserve(r.rateLimit, n, math.MaxInt64)
+}
@@ -20,13 +20,22 @@ func ParseProxy(str string) (proto, login, password, host string, port int, e er
 		var spl = strings.Split(str[schemeSepIndex+3:], "@")
 		if len(spl) == 1 {
 			var credentials = strings.Split(spl[0], ":")
+			if len(credentials) != 2 {
+				return "", "", "", "", 0, errors.New("incorrect proxy format")
+			}
 			host = credentials[0]
 			port, e = strconv.Atoi(credentials[1])
 		} else if len(spl) == 2 {
-			var credentials = strings.Split(spl[0], ":")
+			var credentials = strings.SplitN(spl[0], ":", 2)
 			var address = strings.Split(spl[1], ":")
+			if len(address) != 2 {
+				return "", "", "", "", 0, errors.New("incorrect proxy format")
+			}
 
-			login, password = credentials[0], credentials[1]
+			login = credentials[0]
+			if len(credentials) == 2 {
+				password = credentials[1]
+			}
 			host = address[0]
 			port, e = strconv.Atoi(address[1])
 		} else {
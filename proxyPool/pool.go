@@ -0,0 +1,326 @@
+package proxyPool
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-resty/resty/v2"
+	"github.com/k773/utils"
+	"github.com/k773/utils/rotator"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Options struct {
+	Rotator rotator.Options
+	// DefaultType is the type of the proxies parsed without it.
+	// Default: http
+	DefaultType string
+	// CheckURL is the endpoint requested through every proxy by Check; a 2xx
+	// response means the proxy is alive. The checks are disabled if it is
+	// empty.
+	CheckURL string
+	// Default: 10s
+	CheckTimeout time.Duration
+	// CheckConcurrency is the amount of the proxies checked at once.
+	// Default: 64
+	CheckConcurrency int
+	// BanDuration is the time a proxy is not used for a host after Lease.Ban.
+	// Default: 30m
+	BanDuration time.Duration
+	// LoadTimeout is the timeout of downloading a list by LoadURL.
+	// Default: 30s
+	LoadTimeout time.Duration
+}
+
+// Pool is a deduplicated set of proxies handed out by a rotator.HealthRotator,
+// skipping the proxies which failed the health check or are banned by the
+// target host.
+type Pool struct {
+	opts Options
+	r    *rotator.HealthRotator[*Proxy]
+
+	s   sync.Mutex
+	ids map[string]int // proxy key -> rotator id
+}
+
+func New(opts Options) *Pool {
+	if opts.DefaultType == "" {
+		opts.DefaultType = "http"
+	}
+	if opts.CheckTimeout <= 0 {
+		opts.CheckTimeout = 10 * time.Second
+	}
+	if opts.CheckConcurrency <= 0 {
+		opts.CheckConcurrency = 64
+	}
+	if opts.BanDuration <= 0 {
+		opts.BanDuration = 30 * time.Minute
+	}
+	if opts.LoadTimeout <= 0 {
+		opts.LoadTimeout = 30 * time.Second
+	}
+	return &Pool{opts: opts, r: rotator.NewHealthRotator[*Proxy](opts.Rotator), ids: map[string]int{}}
+}
+
+/* Proxies */
+
+// Add adds the proxy unless it is already in the pool, returning the pool's
+// one.
+func (p *Pool) Add(data *utils.ProxyData) (proxy *Proxy, added bool) {
+	if data.ProxyType == "" {
+		data.ProxyType = p.opts.DefaultType
+	}
+	proxy = newProxy(data)
+
+	p.s.Lock()
+	defer p.s.Unlock()
+	if id, ok := p.ids[proxy.key]; ok {
+		existing, _ := p.r.Get(id)
+		return existing, false
+	}
+	p.ids[proxy.key] = p.r.Add(proxy, 1)
+	return proxy, true
+}
+
+// Remove removes the proxy; its active leases stay valid.
+func (p *Pool) Remove(proxy *Proxy) bool {
+	p.s.Lock()
+	defer p.s.Unlock()
+
+	id, ok := p.ids[proxy.key]
+	if !ok {
+		return false
+	}
+	delete(p.ids, proxy.key)
+	return p.r.Remove(id)
+}
+
+func (p *Pool) Len() int {
+	return p.r.Len()
+}
+
+func (p *Pool) Proxies() []*Proxy {
+	p.s.Lock()
+	defer p.s.Unlock()
+
+	res := make([]*Proxy, 0, len(p.ids))
+	for _, id := range p.ids {
+		if proxy, ok := p.r.Get(id); ok {
+			res = append(res, proxy)
+		}
+	}
+	return res
+}
+
+// Stats returns the rotator statistics of the proxy.
+func (p *Pool) Stats(proxy *Proxy) (stats rotator.Stats, ok bool) {
+	p.s.Lock()
+	id, ok := p.ids[proxy.key]
+	p.s.Unlock()
+	if !ok {
+		return stats, false
+	}
+	return p.r.Stats(id)
+}
+
+/* Lists */
+
+// AddList adds the proxies from the list having one proxy per line in any
+// format supported by utils.ParseProxy. Empty lines and lines starting with #
+// are skipped. The incorrect lines are skipped too and reported by the
+// returned error.
+func (p *Pool) AddList(r io.Reader) (added int, e error) {
+	var errs []error
+	var scanner = bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		var s = strings.TrimSpace(scanner.Text())
+		if s == "" || strings.HasPrefix(s, "#") {
+			continue
+		}
+		data, e := utils.ParseProxyToProxyData(s)
+		if e != nil {
+			errs = append(errs, fmt.Errorf("line %d: %w", line, e))
+			continue
+		}
+		if _, ok := p.Add(data); ok {
+			added++
+		}
+	}
+	if e = scanner.Err(); e != nil {
+		errs = append(errs, e)
+	}
+	return added, errors.Join(errs...)
+}
+
+// LoadFile adds the proxies from the list file; see AddList.
+func (p *Pool) LoadFile(path string) (added int, e error) {
+	f, e := os.Open(path)
+	if e != nil {
+		return 0, e
+	}
+	defer f.Close()
+	return p.AddList(f)
+}
+
+// LoadURL adds the proxies from the list downloaded from the url; see AddList.
+func (p *Pool) LoadURL(ctx context.Context, url string) (added int, e error) {
+	resp, e := resty.New().SetTimeout(p.opts.LoadTimeout).R().SetContext(ctx).Get(url)
+	if e != nil {
+		return 0, e
+	}
+	if resp.IsError() {
+		return 0, fmt.Errorf("proxyPool: loading %s: %s", url, resp.Status())
+	}
+	return p.AddList(bytes.NewReader(resp.Body()))
+}
+
+/* Leases */
+
+// Lease is a proxy taken from the Pool for the target host. It must be
+// returned using Success, Failure, Ban or Return.
+type Lease struct {
+	*Proxy
+
+	pool   *Pool
+	l      *rotator.Lease[*Proxy]
+	target string // host
+}
+
+// TryAcquire leases a proxy for the target host if one is available now. The
+// host may be empty if the bans are not used.
+func (p *Pool) TryAcquire(host string) (l *Lease, ok bool) {
+	rl, ok := p.r.TryAcquireFunc(p.filter(host))
+	if !ok {
+		return nil, false
+	}
+	return p.lease(rl, host), true
+}
+
+// Acquire leases a proxy for the target host, waiting until one is available
+// or ctx is done. It returns rotator.ErrNoItems if the pool is empty.
+func (p *Pool) Acquire(ctx context.Context, host string) (l *Lease, e error) {
+	rl, e := p.r.AcquireFunc(ctx, p.filter(host))
+	if e != nil {
+		return nil, e
+	}
+	return p.lease(rl, host), nil
+}
+
+func (p *Pool) filter(host string) func(proxy *Proxy) bool {
+	return func(proxy *Proxy) bool {
+		return proxy.usable(host, time.Now())
+	}
+}
+
+func (p *Pool) lease(rl *rotator.Lease[*Proxy], host string) *Lease {
+	return &Lease{Proxy: rl.Value, pool: p, l: rl, target: host}
+}
+
+// Success returns the lease, recording the latency of the request made through
+// the proxy for the host. A zero latency is not recorded.
+func (l *Lease) Success(latency time.Duration) error {
+	l.s.Lock()
+	st := l.host(l.target)
+	st.Successes++
+	switch {
+	case latency <= 0:
+	case st.Latency == 0:
+		st.Latency = latency
+	default:
+		st.Latency += time.Duration(latencyWeight * float64(latency-st.Latency))
+	}
+	l.s.Unlock()
+	return l.l.Success()
+}
+
+// Failure returns the lease, putting the proxy on a cooldown.
+func (l *Lease) Failure() error {
+	l.s.Lock()
+	l.host(l.target).Failures++
+	l.s.Unlock()
+	return l.l.Failure()
+}
+
+// Ban returns the lease, excluding the proxy for the host for
+// Options.BanDuration. The ban is recorded against the host only: the proxy
+// keeps its health and is handed out for the other hosts.
+func (l *Lease) Ban() error {
+	l.s.Lock()
+	st := l.host(l.target)
+	st.Failures++
+	st.BannedUntil = time.Now().Add(l.pool.opts.BanDuration)
+	l.s.Unlock()
+
+	time.AfterFunc(l.pool.opts.BanDuration, l.pool.r.Refresh)
+	return l.l.Return()
+}
+
+// Return returns the lease without a result.
+func (l *Lease) Return() error {
+	return l.l.Return()
+}
+
+/* Health checks */
+
+// Check checks every proxy by requesting Options.CheckURL through it. The
+// proxies failing the check are not handed out until they pass it.
+func (p *Pool) Check(ctx context.Context) {
+	if p.opts.CheckURL == "" {
+		return
+	}
+
+	var wg sync.WaitGroup
+	var sem = make(chan struct{}, p.opts.CheckConcurrency)
+loop:
+	for _, proxy := range p.Proxies() {
+		select {
+		case <-ctx.Done():
+			break loop
+		case sem <- struct{}{}:
+		}
+		wg.Add(1)
+		go func(proxy *Proxy) {
+			defer func() { <-sem; wg.Done() }()
+			p.check(ctx, proxy)
+		}(proxy)
+	}
+	wg.Wait()
+	p.r.Refresh()
+}
+
+func (p *Pool) check(ctx context.Context, proxy *Proxy) {
+	var client = proxy.checkClient(p.opts.CheckTimeout)
+	// The connections are not kept until the next check.
+	defer client.GetClient().CloseIdleConnections()
+
+	var t0 = time.Now()
+	resp, e := client.R().SetContext(ctx).Get(p.opts.CheckURL)
+	if ctx.Err() != nil {
+		// The check was interrupted, not failed.
+		return
+	}
+	if e == nil && resp.IsError() {
+		e = fmt.Errorf("proxyPool: check: %s", resp.Status())
+	}
+	proxy.setCheckResult(time.Since(t0), e)
+}
+
+// Run checks the proxies in an indefinite loop. Call is not mandatory. Ticker will be stopped when ctx is done.
+func (p *Pool) Run(ctx context.Context, ticker *time.Ticker) {
+	defer ticker.Stop()
+loop:
+	for range ticker.C {
+		select {
+		case <-ctx.Done():
+			break loop
+		default:
+			p.Check(ctx)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package proxyPool
+
+import (
+	"errors"
+	"github.com/go-resty/resty/v2"
+	"github.com/k773/utils"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttpproxy"
+	"strings"
+	"sync"
+	"time"
+)
+
+// latencyWeight is the weight of a new sample in the latency moving averages.
+const latencyWeight = 0.2
+
+var ErrUnsupportedProxyType = errors.New("proxyPool: unsupported proxy type")
+
+// Proxy is a proxy of the Pool with its check results and per target host
+// statistics.
+type Proxy struct {
+	*utils.ProxyData
+
+	key string
+
+	s sync.Mutex
+	// alive is true until a check fails.
+	alive        bool
+	checkedAt    time.Time
+	checkLatency time.Duration
+	checkError   error
+	// checker is the client of the checks, reused by them.
+	checker *resty.Client
+	hosts   map[string]*HostStats
+}
+
+// HostStats are the statistics of a proxy for a target host.
+type HostStats struct {
+	// Latency is the moving average of the requests' latencies reported by
+	// Lease.Success.
+	Latency             time.Duration
+	Successes, Failures int64
+	BannedUntil         time.Time
+}
+
+// CheckResult is the result of the last health check of a proxy.
+type CheckResult struct {
+	Alive     bool
+	CheckedAt time.Time
+	Latency   time.Duration
+	Error     error
+}
+
+func newProxy(data *utils.ProxyData) *Proxy {
+	return &Proxy{ProxyData: data, key: proxyKey(data), alive: true, hosts: map[string]*HostStats{}}
+}
+
+func proxyKey(data *utils.ProxyData) string {
+	return strings.ToLower(data.ProxyType) + "://" + data.StringNoType()
+}
+
+func (p *Proxy) Alive() bool {
+	p.s.Lock()
+	defer p.s.Unlock()
+
+	return p.alive
+}
+
+func (p *Proxy) LastCheck() CheckResult {
+	p.s.Lock()
+	defer p.s.Unlock()
+
+	return CheckResult{Alive: p.alive, CheckedAt: p.checkedAt, Latency: p.checkLatency, Error: p.checkError}
+}
+
+// HostStats returns the statistics for the target host.
+func (p *Proxy) HostStats(host string) HostStats {
+	p.s.Lock()
+	defer p.s.Unlock()
+
+	if st := p.hosts[host]; st != nil {
+		return *st
+	}
+	return HostStats{}
+}
+
+// Banned reports whether the proxy is banned by the target host.
+func (p *Proxy) Banned(host string) bool {
+	p.s.Lock()
+	defer p.s.Unlock()
+
+	return p.banned(host, time.Now())
+}
+
+// banned must be called with p.s held.
+func (p *Proxy) banned(host string, now time.Time) bool {
+	st := p.hosts[host]
+	return st != nil && now.Before(st.BannedUntil)
+}
+
+// usable reports whether the proxy is alive and not banned by the host.
+func (p *Proxy) usable(host string, now time.Time) bool {
+	p.s.Lock()
+	defer p.s.Unlock()
+
+	return p.alive && !p.banned(host, now)
+}
+
+// host must be called with p.s held.
+func (p *Proxy) host(host string) *HostStats {
+	st := p.hosts[host]
+	if st == nil {
+		st = new(HostStats)
+		p.hosts[host] = st
+	}
+	return st
+}
+
+func (p *Proxy) setCheckResult(latency time.Duration, e error) {
+	p.s.Lock()
+	defer p.s.Unlock()
+
+	p.alive = e == nil
+	p.checkedAt = time.Now()
+	p.checkLatency = latency
+	p.checkError = e
+}
+
+// checkClient returns the client of the checks, creating it on the first call.
+func (p *Proxy) checkClient(timeout time.Duration) *resty.Client {
+	p.s.Lock()
+	defer p.s.Unlock()
+
+	if p.checker == nil {
+		p.checker = p.Resty().SetTimeout(timeout)
+	}
+	return p.checker
+}
+
+// Resty returns a new resty client using the proxy.
+func (p *Proxy) Resty() *resty.Client {
+	return resty.New().SetProxy(p.String())
+}
+
+// FastHttp returns a new fasthttp client using the proxy. Only the http and
+// socks5 proxies are supported.
+func (p *Proxy) FastHttp() (c *fasthttp.Client, e error) {
+	var dial fasthttp.DialFunc
+	switch strings.ToLower(p.ProxyType) {
+	case "http", "https":
+		dial = fasthttpproxy.FasthttpHTTPDialerTimeout(p.StringNoType(), 60*time.Second)
+	case "socks5", "socks5h":
+		dial = fasthttpproxy.FasthttpSocksDialer("socks5://" + p.StringNoType())
+	default:
+		return nil, ErrUnsupportedProxyType
+	}
+	return &fasthttp.Client{Dial: dial, MaxConnsPerHost: 10}, nil
+}
@@ -109,7 +109,8 @@ func (r *RateLimiterV3) Wait() {
 	defer r.l.Unlock()
 
 	var t0 = time.Now()
-	var td = r.b - Clamp(t0.Sub(r.lastRequest), 0, r.b)
+	// lastRequest is in the future if it was reserved using Limiter.
+	var td = r.b - min(t0.Sub(r.lastRequest), r.b)
 	if !r.lastRequest.IsZero() {
 		time.Sleep(td)
 	}
@@ -135,7 +136,7 @@ func (r *RateLimiterV4) Wait(sinceLastRelease time.Duration) {
 	defer r.l.Unlock()
 
 	var t0 = time.Now()
-	var td = sinceLastRelease - Clamp(t0.Sub(r.lastRequest), 0, sinceLastRelease)
+	var td = sinceLastRelease - min(t0.Sub(r.lastRequest), sinceLastRelease)
 	if !r.lastRequest.IsZero() {
 		time.Sleep(td)
 	}
@@ -178,7 +179,7 @@ func (r *RateLimiterV5) Wait(ctx context.Context, sinceLastRelease time.Duration
 		r.throttledUntil = time.Time{}
 
 		if !r.lastRequest.IsZero() {
-			var sleepFor = sinceLastRelease - Clamp(time.Now().Sub(r.lastRequest), 0, sinceLastRelease)
+			var sleepFor = sinceLastRelease - min(time.Now().Sub(r.lastRequest), sinceLastRelease)
 			e = SleepWithContext(ctx, sleepFor)
 		}
 	}
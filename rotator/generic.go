@@ -0,0 +1,460 @@
+package rotator
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+type Strategy int
+
+const (
+	RoundRobin Strategy = iota
+	Random
+	// Weighted picks randomly with the probability proportional to the
+	// item's weight multiplied by its health.
+	Weighted
+	LeastRecentlyUsed
+)
+
+var (
+	ErrNoItems      = errors.New("rotator: no items")
+	ErrLeaseExpired = errors.New("rotator: lease expired")
+)
+
+type Options struct {
+	Strategy Strategy
+	// MaxLeases is the amount of leases an item may have at once; 0 means no
+	// limit.
+	MaxLeases int
+	// LeaseTimeout is the time after which a lease not returned is released
+	// and counted as a timeout; 0 means no timeout.
+	LeaseTimeout time.Duration
+	// FailureCooldown is the time an item is not used after a failure; it is
+	// doubled by every consecutive failure, up to MaxCooldown.
+	// Default: 10s
+	FailureCooldown time.Duration
+	// Default: 10m
+	MaxCooldown time.Duration
+	// HealthWeight is the weight of a result in the item's health, the
+	// exponential moving average of its success rate.
+	// Default: 0.2
+	HealthWeight float64
+	// MinHealth is the health below which an item is used only if there are
+	// no healthier items available.
+	MinHealth float64
+	// SessionTTL is the time a session stays bound to its item after the last
+	// AcquireSession.
+	// Default: 30m
+	SessionTTL time.Duration
+}
+
+// Stats are the statistics of an item.
+type Stats struct {
+	Weight float64
+	// Health is the exponential moving average of the success rate, in
+	// [0, 1]; a new item has the health of 1.
+	Health              float64
+	Leases              int64
+	Successes, Failures int64
+	Timeouts            int64
+	// ActiveLeases is the amount of the leases not returned yet.
+	ActiveLeases        int
+	ConsecutiveFailures int
+	CooldownUntil       time.Time
+	LastUsed            time.Time
+	// LeaseTime is the total time the leases were held.
+	LeaseTime time.Duration
+}
+
+type item[T any] struct {
+	id      int
+	value   T
+	stats   Stats
+	removed bool
+}
+
+// available reports whether the item can be leased at now.
+func (i *item[T]) available(now time.Time, maxLeases int) bool {
+	return !now.Before(i.stats.CooldownUntil) && (maxLeases <= 0 || i.stats.ActiveLeases < maxLeases)
+}
+
+// HealthRotator hands out leases on its items using the selection strategy, skipping
+// the items cooling down after a failure.
+type HealthRotator[T any] struct {
+	opts Options
+
+	s     sync.Mutex
+	items []*item[T]
+	byId  map[int]*item[T]
+	next  int // round-robin position
+	id    int
+	// changed is closed and replaced when a lease may become available.
+	changed chan struct{}
+
+	sessions   map[string]*session[T]
+	onReassign func(key string, from, to T)
+}
+
+func NewHealthRotator[T any](opts Options) *HealthRotator[T] {
+	if opts.FailureCooldown <= 0 {
+		opts.FailureCooldown = 10 * time.Second
+	}
+	if opts.MaxCooldown <= 0 {
+		opts.MaxCooldown = 10 * time.Minute
+	}
+	if opts.HealthWeight <= 0 || opts.HealthWeight > 1 {
+		opts.HealthWeight = 0.2
+	}
+	if opts.SessionTTL <= 0 {
+		opts.SessionTTL = 30 * time.Minute
+	}
+	return &HealthRotator[T]{opts: opts, byId: map[int]*item[T]{}, changed: make(chan struct{}), sessions: map[string]*session[T]{}}
+}
+
+// notify must be called with r.s held.
+func (r *HealthRotator[T]) notify() {
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+// Add adds the item and returns its id. The weight is used by the Weighted
+// strategy; a non-positive weight means 1.
+func (r *HealthRotator[T]) Add(value T, weight float64) (id int) {
+	r.s.Lock()
+	defer r.s.Unlock()
+
+	if weight <= 0 {
+		weight = 1
+	}
+	it := &item[T]{id: r.id, value: value, stats: Stats{Weight: weight, Health: 1}}
+	r.id++
+	r.items = append(r.items, it)
+	r.byId[it.id] = it
+	r.notify()
+	return it.id
+}
+
+// Remove removes the item; its active leases stay valid.
+func (r *HealthRotator[T]) Remove(id int) bool {
+	r.s.Lock()
+	defer r.s.Unlock()
+
+	it := r.byId[id]
+	if it == nil {
+		return false
+	}
+	r.remove(it)
+	return true
+}
+
+// remove must be called with r.s held.
+func (r *HealthRotator[T]) remove(it *item[T]) {
+	it.removed = true
+	delete(r.byId, it.id)
+	for i, v := range r.items {
+		if v == it {
+			r.items = append(r.items[:i], r.items[i+1:]...)
+			if r.next > i {
+				r.next--
+			}
+			break
+		}
+	}
+}
+
+func (r *HealthRotator[T]) Get(id int) (value T, ok bool) {
+	r.s.Lock()
+	defer r.s.Unlock()
+
+	if it := r.byId[id]; it != nil {
+		return it.value, true
+	}
+	return value, false
+}
+
+func (r *HealthRotator[T]) Len() int {
+	r.s.Lock()
+	defer r.s.Unlock()
+
+	return len(r.items)
+}
+
+// Stats returns the statistics of the item.
+func (r *HealthRotator[T]) Stats(id int) (stats Stats, ok bool) {
+	r.s.Lock()
+	defer r.s.Unlock()
+
+	if it := r.byId[id]; it != nil {
+		return it.stats, true
+	}
+	return stats, false
+}
+
+// AllStats returns the statistics of every item by its id.
+func (r *HealthRotator[T]) AllStats() map[int]Stats {
+	r.s.Lock()
+	defer r.s.Unlock()
+
+	res := make(map[int]Stats, len(r.items))
+	for _, it := range r.items {
+		res[it.id] = it.stats
+	}
+	return res
+}
+
+// TryAcquire leases an item if one is available now.
+func (r *HealthRotator[T]) TryAcquire() (l *Lease[T], ok bool) {
+	return r.TryAcquireFunc(nil)
+}
+
+// TryAcquireFunc is like TryAcquire, but leases only the items accepted by
+// filter. Filter is called with the rotator locked, so it must not use the
+// rotator.
+func (r *HealthRotator[T]) TryAcquireFunc(filter func(value T) bool) (l *Lease[T], ok bool) {
+	r.s.Lock()
+	defer r.s.Unlock()
+
+	if it, _ := r.pick(time.Now(), itemFilter[T](filter)); it != nil {
+		return r.lease(it), true
+	}
+	return nil, false
+}
+
+// Acquire leases an item, waiting until one is available or ctx is done. It
+// returns ErrNoItems if the rotator is empty.
+func (r *HealthRotator[T]) Acquire(ctx context.Context) (l *Lease[T], e error) {
+	return r.AcquireFunc(ctx, nil)
+}
+
+// AcquireFunc is like Acquire, but leases only the items accepted by filter.
+// The filter is rechecked when the rotator changes; call Refresh if it may
+// accept more items otherwise.
+func (r *HealthRotator[T]) AcquireFunc(ctx context.Context, filter func(value T) bool) (l *Lease[T], e error) {
+	for e == nil {
+		r.s.Lock()
+		if len(r.items) == 0 {
+			r.s.Unlock()
+			return nil, ErrNoItems
+		}
+		it, wakeAt := r.pick(time.Now(), itemFilter[T](filter))
+		if it != nil {
+			l = r.lease(it)
+			r.s.Unlock()
+			return
+		}
+		changed := r.changed
+		r.s.Unlock()
+
+		e = r.wait(ctx, changed, wakeAt)
+	}
+	return
+}
+
+// Refresh wakes up the Acquire calls waiting for an item.
+func (r *HealthRotator[T]) Refresh() {
+	r.s.Lock()
+	defer r.s.Unlock()
+
+	r.notify()
+}
+
+func itemFilter[T any](filter func(value T) bool) func(it *item[T]) bool {
+	if filter == nil {
+		return nil
+	}
+	return func(it *item[T]) bool {
+		return filter(it.value)
+	}
+}
+
+// wait waits for the change, the time (if it is not zero) or ctx.
+func (r *HealthRotator[T]) wait(ctx context.Context, changed chan struct{}, wakeAt time.Time) error {
+	var timer <-chan time.Time
+	if !wakeAt.IsZero() {
+		t := time.NewTimer(time.Until(wakeAt))
+		defer t.Stop()
+		timer = t.C
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-changed:
+	case <-timer:
+	}
+	return nil
+}
+
+// pick selects an available item accepted by filter (if it is not nil). If
+// there is none, wakeAt is the end of the earliest cooldown, or zero if only a
+// return of a lease can make an item available. It must be called with r.s
+// held.
+func (r *HealthRotator[T]) pick(now time.Time, filter func(it *item[T]) bool) (res *item[T], wakeAt time.Time) {
+	var healthy, weak []int // indexes in r.items
+	for i, it := range r.items {
+		if filter != nil && !filter(it) {
+			continue
+		}
+		if !it.available(now, r.opts.MaxLeases) {
+			if now.Before(it.stats.CooldownUntil) && (wakeAt.IsZero() || it.stats.CooldownUntil.Before(wakeAt)) {
+				wakeAt = it.stats.CooldownUntil
+			}
+			continue
+		}
+		if it.stats.Health >= r.opts.MinHealth {
+			healthy = append(healthy, i)
+		} else {
+			weak = append(weak, i)
+		}
+	}
+	candidates := healthy
+	if len(candidates) == 0 {
+		candidates = weak
+	}
+	if len(candidates) == 0 {
+		return nil, wakeAt
+	}
+
+	switch r.opts.Strategy {
+	case Random:
+		return r.items[candidates[rand.Intn(len(candidates))]], time.Time{}
+	case Weighted:
+		var total float64
+		for _, i := range candidates {
+			total += r.items[i].weight()
+		}
+		x := rand.Float64() * total
+		for _, i := range candidates {
+			if x -= r.items[i].weight(); x < 0 {
+				return r.items[i], time.Time{}
+			}
+		}
+		return r.items[candidates[len(candidates)-1]], time.Time{}
+	case LeastRecentlyUsed:
+		res = r.items[candidates[0]]
+		for _, i := range candidates[1:] {
+			if r.items[i].stats.LastUsed.Before(res.stats.LastUsed) {
+				res = r.items[i]
+			}
+		}
+		return res, time.Time{}
+	default:
+		// The first candidate at or after the round-robin position.
+		pos := candidates[0]
+		for _, i := range candidates {
+			if i >= r.next {
+				pos = i
+				break
+			}
+		}
+		r.next = (pos + 1) % len(r.items)
+		return r.items[pos], time.Time{}
+	}
+}
+
+func (i *item[T]) weight() float64 {
+	// A zero health must not exclude the item completely.
+	return i.stats.Weight * max(i.stats.Health, 0.01)
+}
+
+// lease must be called with r.s held.
+func (r *HealthRotator[T]) lease(it *item[T]) *Lease[T] {
+	now := time.Now()
+	it.stats.Leases++
+	it.stats.ActiveLeases++
+	it.stats.LastUsed = now
+
+	l := &Lease[T]{Value: it.value, ID: it.id, r: r, item: it, acquired: now}
+	if r.opts.LeaseTimeout > 0 {
+		l.timer = time.AfterFunc(r.opts.LeaseTimeout, func() {
+			r.release(l, resultTimeout)
+		})
+	}
+	return l
+}
+
+type leaseResult int
+
+const (
+	resultNone leaseResult = iota
+	resultSuccess
+	resultFailure
+	resultTimeout
+)
+
+// release returns the lease, reporting false if it was already returned.
+func (r *HealthRotator[T]) release(l *Lease[T], result leaseResult) bool {
+	r.s.Lock()
+	defer r.s.Unlock()
+
+	if l.returned {
+		return false
+	}
+	l.returned = true
+	if l.timer != nil {
+		l.timer.Stop()
+	}
+
+	now := time.Now()
+	st := &l.item.stats
+	st.ActiveLeases--
+	st.LeaseTime += now.Sub(l.acquired)
+	switch result {
+	case resultSuccess:
+		st.Successes++
+		st.ConsecutiveFailures = 0
+		st.Health += r.opts.HealthWeight * (1 - st.Health)
+	case resultFailure:
+		st.Failures++
+		st.ConsecutiveFailures++
+		st.Health -= r.opts.HealthWeight * st.Health
+		cooldown := r.opts.FailureCooldown << min(st.ConsecutiveFailures-1, 30)
+		if cooldown <= 0 || cooldown > r.opts.MaxCooldown {
+			cooldown = r.opts.MaxCooldown
+		}
+		st.CooldownUntil = now.Add(cooldown)
+	case resultTimeout:
+		st.Timeouts++
+	}
+	r.notify()
+	return true
+}
+
+// Lease is an item taken from the HealthRotator. It must be returned using Success,
+// Failure or Return.
+type Lease[T any] struct {
+	Value T
+	ID    int
+
+	r        *HealthRotator[T]
+	item     *item[T]
+	acquired time.Time
+	timer    *time.Timer
+	returned bool // guarded by r.s
+}
+
+// Success returns the lease, improving the item's health. It returns
+// ErrLeaseExpired if the lease was already returned or timed out.
+func (l *Lease[T]) Success() error {
+	return l.done(resultSuccess)
+}
+
+// Failure returns the lease, lowering the item's health and putting it on a
+// cooldown.
+func (l *Lease[T]) Failure() error {
+	return l.done(resultFailure)
+}
+
+// Return returns the lease without a result.
+func (l *Lease[T]) Return() error {
+	return l.done(resultNone)
+}
+
+func (l *Lease[T]) done(result leaseResult) error {
+	if !l.r.release(l, result) {
+		return ErrLeaseExpired
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package rotator
+
+import (
+	"context"
+	"time"
+)
+
+// session is the binding of a session key to an item.
+type session[T any] struct {
+	item    *item[T]
+	expires time.Time
+}
+
+// SetOnReassign sets the function called when a session is moved to another
+// item because its item was removed or became unhealthy. It is called by
+// AcquireSession before the lease on the new item is returned, so the session
+// state can be migrated.
+func (r *HealthRotator[T]) SetOnReassign(f func(key string, from, to T)) {
+	r.s.Lock()
+	defer r.s.Unlock()
+
+	r.onReassign = f
+}
+
+// TryAcquireSession leases the item bound to the session key if it is
+// available now. A new session, or one whose item was removed, is on a
+// cooldown or has the health below Options.MinHealth, is bound to the item
+// picked by the strategy.
+func (r *HealthRotator[T]) TryAcquireSession(key string) (l *Lease[T], ok bool) {
+	r.s.Lock()
+	l, _, reassigned := r.leaseSession(time.Now(), key)
+	r.s.Unlock()
+
+	if reassigned != nil {
+		reassigned()
+	}
+	return l, l != nil
+}
+
+// AcquireSession is like TryAcquireSession, but waits until the item is
+// available or ctx is done. It returns ErrNoItems if the rotator is empty.
+func (r *HealthRotator[T]) AcquireSession(ctx context.Context, key string) (l *Lease[T], e error) {
+	for e == nil {
+		r.s.Lock()
+		if len(r.items) == 0 {
+			r.s.Unlock()
+			return nil, ErrNoItems
+		}
+		l, wakeAt, reassigned := r.leaseSession(time.Now(), key)
+		changed := r.changed
+		r.s.Unlock()
+
+		if l != nil {
+			if reassigned != nil {
+				reassigned()
+			}
+			return l, nil
+		}
+		e = r.wait(ctx, changed, wakeAt)
+	}
+	return
+}
+
+// leaseSession leases the session's item, binding the session to a new one if
+// needed. If there is no item available, wakeAt is as returned by pick.
+// Reassigned calls the onReassign function if it is not nil; it must be called
+// without r.s held. It must be called with r.s held.
+func (r *HealthRotator[T]) leaseSession(now time.Time, key string) (l *Lease[T], wakeAt time.Time, reassigned func()) {
+	s := r.sessions[key]
+	if s != nil && now.After(s.expires) {
+		delete(r.sessions, key)
+		s = nil
+	}
+
+	if s != nil && r.healthy(s.item, now) {
+		if !s.item.available(now, r.opts.MaxLeases) {
+			// Waiting for a lease to be returned.
+			return nil, time.Time{}, nil
+		}
+		s.expires = now.Add(r.opts.SessionTTL)
+		return r.lease(s.item), time.Time{}, nil
+	}
+
+	it, wakeAt := r.pick(now, nil)
+	if it == nil {
+		return nil, wakeAt, nil
+	}
+	if s != nil && s.item != it && r.onReassign != nil {
+		f, from, to := r.onReassign, s.item.value, it.value
+		reassigned = func() { f(key, from, to) }
+	}
+	r.sessions[key] = &session[T]{item: it, expires: now.Add(r.opts.SessionTTL)}
+	return r.lease(it), time.Time{}, reassigned
+}
+
+// healthy reports whether the session may stay on the item.
+func (r *HealthRotator[T]) healthy(it *item[T], now time.Time) bool {
+	return !it.removed && !now.Before(it.stats.CooldownUntil) && it.stats.Health >= r.opts.MinHealth
+}
+
+// Session returns the id of the item bound to the session key.
+func (r *HealthRotator[T]) Session(key string) (id int, ok bool) {
+	r.s.Lock()
+	defer r.s.Unlock()
+
+	if s := r.sessions[key]; s != nil && !time.Now().After(s.expires) && !s.item.removed {
+		return s.item.id, true
+	}
+	return 0, false
+}
+
+// Unbind removes the session, so the next AcquireSession binds it to a new item
+// without calling the onReassign function.
+func (r *HealthRotator[T]) Unbind(key string) {
+	r.s.Lock()
+	defer r.s.Unlock()
+
+	delete(r.sessions, key)
+}
+
+// Run removes the expired sessions in an indefinite loop. Call is not mandatory. Ticker will be stopped when ctx is done.
+func (r *HealthRotator[T]) Run(ctx context.Context, ticker *time.Ticker) {
+	defer ticker.Stop()
+loop:
+	for now := range ticker.C {
+		select {
+		case <-ctx.Done():
+			break loop
+		default:
+			r.removeExpiredSessions(now)
+		}
+	}
+}
+
+func (r *HealthRotator[T]) removeExpiredSessions(now time.Time) {
+	r.s.Lock()
+	defer r.s.Unlock()
+
+	for key, s := range r.sessions {
+		if now.After(s.expires) {
+			delete(r.sessions, key)
+		}
+	}
+}
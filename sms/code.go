@@ -0,0 +1,49 @@
+package sms
+
+import "regexp"
+
+// DefaultCodePatterns matches a standalone 4-8 digit number, optionally split by a dash or space in the middle.
+var DefaultCodePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\b(\d{3})[- ](\d{3})\b`),
+	regexp.MustCompile(`\b(\d{4,8})\b`),
+}
+
+// CodeExtractor extracts the codes from the message texts. Patterns are tried in order; the capturing groups of the
+// first matched pattern are concatenated, if a pattern has no groups, the entire match is used.
+type CodeExtractor struct {
+	Patterns []*regexp.Regexp
+}
+
+func NewCodeExtractor(patterns ...string) (c *CodeExtractor, e error) {
+	c = new(CodeExtractor)
+	for _, p := range patterns {
+		var re *regexp.Regexp
+		if re, e = regexp.Compile(p); e != nil {
+			return nil, e
+		}
+		c.Patterns = append(c.Patterns, re)
+	}
+	return
+}
+
+func (c *CodeExtractor) Extract(text string) (code string, ok bool) {
+	var patterns = DefaultCodePatterns
+	if c != nil && len(c.Patterns) != 0 {
+		patterns = c.Patterns
+	}
+
+	for _, re := range patterns {
+		var match = re.FindStringSubmatch(text)
+		if match == nil {
+			continue
+		}
+		if len(match) == 1 {
+			return match[0], true
+		}
+		for _, group := range match[1:] {
+			code += group
+		}
+		return code, true
+	}
+	return "", false
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/go-resty/resty/v2"
 	"github.com/k773/utils"
 	"github.com/k773/utils/fixedPoint"
+	"github.com/k773/utils/sms"
 	"strconv"
 	"time"
 )
@@ -15,6 +16,8 @@ type Provider struct {
 	// BusyPollingInterval is used by the methods that are continuously polling data from the api.
 	// Default: 15sec.
 	BusyPollingInterval time.Duration
+
+	poller statePoller
 }
 
 func New(key string) *Provider {
@@ -43,6 +46,12 @@ func (p *Provider) ApiGetBalance(ctx context.Context) (resp GetBalanceResponse,
 	return
 }
 
+// GetBalance implements sms.Provider; the balance is in usd.
+func (p *Provider) GetBalance(ctx context.Context) (balance fixedPoint.FP, e error) {
+	resp, e := p.ApiGetBalance(ctx)
+	return resp.Balance, e
+}
+
 /*
 	Get states
 */
@@ -116,6 +125,19 @@ func (p *Provider) GetPhone(ctx context.Context, country int, service string) (p
 	return
 }
 
+// GetActivation implements sms.Provider; country must be a number (see ApiGetPhone).
+func (p *Provider) GetActivation(ctx context.Context, country, service string) (activation sms.Activation, e error) {
+	countryInt, e := strconv.Atoi(country)
+	if e != nil {
+		return
+	}
+	phone, e := p.GetPhone(ctx, countryInt, service)
+	if e == nil {
+		activation = phone
+	}
+	return
+}
+
 type GetPhoneResponse struct {
 	Response StringResponse `json:"response"`
 	// Tzid is the activation id
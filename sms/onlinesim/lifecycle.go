@@ -0,0 +1,217 @@
+package onlinesim
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/k773/utils/files"
+	"os"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Manager journals the in-flight activations to disk, so they can be closed after a crash, and closes the
+// activations when their deadlines are exceeded.
+type Manager struct {
+	p    *Provider
+	path string
+	// DefaultTimeout is used by GetPhone if the timeout is not provided. 0 = not limited.
+	// Default: 0
+	DefaultTimeout time.Duration
+	// RetryInterval is the delay before closing an activation again after the previous attempt failed.
+	// Default: 1m
+	RetryInterval time.Duration
+
+	active map[int]*managedEntry
+	l      sync.Mutex
+}
+
+type JournalEntry struct {
+	Tzid      int       `json:"tzid"`
+	Country   int       `json:"country"`
+	Service   string    `json:"service"`
+	CreatedAt time.Time `json:"created_at"`
+	// Deadline is zero if the activation is not limited in time
+	Deadline time.Time `json:"deadline"`
+}
+
+type managedEntry struct {
+	JournalEntry
+	timer *time.Timer
+}
+
+// NewManager loads the journal from path and closes every activation left there by the previous run. Activations
+// that could not be closed stay in the journal, unless the api reports them as already closed, and are closed again
+// every RetryInterval.
+func NewManager(ctx context.Context, p *Provider, path string) (m *Manager, e error) {
+	m = &Manager{p: p, path: path, RetryInterval: time.Minute, active: map[int]*managedEntry{}}
+
+	data, e := os.ReadFile(path)
+	if errors.Is(e, os.ErrNotExist) {
+		return m, nil
+	}
+	if e != nil {
+		return nil, e
+	}
+	var stale []JournalEntry
+	if e = json.Unmarshal(data, &stale); e != nil {
+		return nil, e
+	}
+
+	var errs []error
+	var failed []*managedEntry
+	for _, entry := range stale {
+		if _, err := p.ApiSetOperationOk(ctx, entry.Tzid); err != nil && !alreadyClosed(err) {
+			failed = append(failed, &managedEntry{JournalEntry: entry})
+			errs = append(errs, err)
+		}
+	}
+
+	m.l.Lock()
+	defer m.l.Unlock()
+	for _, entry := range failed {
+		m.active[entry.Tzid] = entry
+		m.schedule(entry, m.retryInterval())
+	}
+	if e = m.save(); e == nil {
+		e = errors.Join(errs...)
+	}
+	return
+}
+
+// GetPhone orders the number and journals the activation. The activation is closed after timeout; if timeout is not
+// provided, DefaultTimeout is used. If the journal can not be saved, the activation is cancelled and only the error is
+// returned.
+func (m *Manager) GetPhone(ctx context.Context, country int, service string, timeout ...time.Duration) (phone *ManagedActivation, e error) {
+	a, e := m.p.GetPhone(ctx, country, service)
+	if e != nil {
+		return
+	}
+
+	var entry = &managedEntry{JournalEntry: JournalEntry{
+		Tzid:      a.GetPhoneResponse.Tzid,
+		Country:   country,
+		Service:   service,
+		CreatedAt: time.Now(),
+	}}
+	var d = m.DefaultTimeout
+	if len(timeout) != 0 {
+		d = timeout[0]
+	}
+
+	m.l.Lock()
+	m.active[entry.Tzid] = entry
+	if d > 0 {
+		entry.Deadline = entry.CreatedAt.Add(d)
+		m.schedule(entry, d)
+	}
+	if e = m.save(); e != nil {
+		if entry.timer != nil {
+			entry.timer.Stop()
+		}
+		delete(m.active, entry.Tzid)
+	}
+	m.l.Unlock()
+
+	if e != nil {
+		return nil, errors.Join(e, a.Cancel(ctx))
+	}
+	return &ManagedActivation{Activation: a, m: m}, nil
+}
+
+// schedule closes the activation after d, retrying every RetryInterval while closing fails; must be called with m.l
+// locked
+func (m *Manager) schedule(entry *managedEntry, d time.Duration) {
+	entry.timer = time.AfterFunc(d, func() {
+		if e := m.Close(context.Background(), entry.Tzid); e == nil {
+			return
+		}
+		m.l.Lock()
+		defer m.l.Unlock()
+		if m.active[entry.Tzid] == entry {
+			m.schedule(entry, m.retryInterval())
+		}
+	})
+}
+
+// Close closes the activation and removes it from the journal.
+func (m *Manager) Close(ctx context.Context, tzid int) (e error) {
+	if _, e = m.p.ApiSetOperationOk(ctx, tzid); e != nil && !alreadyClosed(e) {
+		return
+	}
+
+	m.l.Lock()
+	defer m.l.Unlock()
+	if entry, ok := m.active[tzid]; ok {
+		if entry.timer != nil {
+			entry.timer.Stop()
+		}
+		delete(m.active, tzid)
+		return m.save()
+	}
+	return nil
+}
+
+// closedResponses are the api responses to setOperationOk meaning the activation is already closed or does not exist.
+var closedResponses = []StringResponse{"ERROR_NO_OPERATIONS"}
+
+// alreadyClosed reports whether the activation can be removed from the journal after the error. Any other error
+// (bad key, rate limiting, outage) keeps it there.
+func alreadyClosed(e error) bool {
+	var unexpected ErrorUnexpectedResponse
+	if !errors.As(e, &unexpected) {
+		return false
+	}
+	response, ok := unexpected.Response.(StringResponse)
+	return ok && slices.Contains(closedResponses, response)
+}
+
+// InFlight returns the journaled activations sorted by creation time.
+func (m *Manager) InFlight() (entries []JournalEntry) {
+	m.l.Lock()
+	defer m.l.Unlock()
+
+	for _, entry := range m.active {
+		entries = append(entries, entry.JournalEntry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].CreatedAt.Before(entries[j].CreatedAt)
+	})
+	return
+}
+
+// save must be called with m.l locked
+func (m *Manager) save() error {
+	var entries = make([]JournalEntry, 0, len(m.active))
+	for _, entry := range m.active {
+		entries = append(entries, entry.JournalEntry)
+	}
+	data, e := json.Marshal(entries)
+	if e != nil {
+		return e
+	}
+	return files.WriteFileAtomic(m.path, data, 0600)
+}
+
+func (m *Manager) retryInterval() time.Duration {
+	if m.RetryInterval <= 0 {
+		return time.Minute
+	}
+	return m.RetryInterval
+}
+
+// ManagedActivation is the Activation that is removed from the manager's journal by Cancel and Finish.
+type ManagedActivation struct {
+	*Activation
+	m *Manager
+}
+
+func (a *ManagedActivation) Cancel(ctx context.Context) error {
+	return a.m.Close(ctx, a.GetPhoneResponse.Tzid)
+}
+
+func (a *ManagedActivation) Finish(ctx context.Context) error {
+	return a.m.Close(ctx, a.GetPhoneResponse.Tzid)
+}
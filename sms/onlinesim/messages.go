@@ -0,0 +1,148 @@
+package onlinesim
+
+import (
+	"context"
+	"github.com/k773/utils/sms"
+	"strconv"
+	"time"
+)
+
+/*
+	Get states with the messages list
+*/
+
+type GetStatesMessagesResponse []*StateMessages
+
+func (g *GetStatesMessagesResponse) ByTzid(tzid int) *StateMessages {
+	for _, state := range *g {
+		if state.Tzid == tzid {
+			return state
+		}
+	}
+	return nil
+}
+
+// StateMessages is the State with the full list of messages received by the activation.
+type StateMessages struct {
+	State
+	Msg []Message `json:"msg"`
+}
+
+type Message struct {
+	Service string `json:"service"`
+	// Msg is the full text of the message
+	Msg string `json:"msg"`
+
+	// ReceivedAt is the time the message was first seen, the field does not exist in the online-sim's api
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+// Code extracts the code from the message text; extractor may be nil (sms.DefaultCodePatterns are used then).
+func (m *Message) Code(extractor *sms.CodeExtractor) (code string, ok bool) {
+	return extractor.Extract(m.Msg)
+}
+
+// ApiGetStatesMessages returns the states with the full text of every message received.
+// pass tzid <= 0 to not send the parameter
+func (p *Provider) ApiGetStatesMessages(ctx context.Context, tzId int) (resp GetStatesMessagesResponse, e error) {
+	request := p.Ses.R().
+		SetQueryParam("msg_list", "1").
+		SetQueryParam("message_to_code", "0")
+	if tzId > 0 {
+		request.SetQueryParam("tzid", strconv.Itoa(tzId))
+	}
+	resp, e = ApiRequest[GetStatesMessagesResponse](ctx, request, "getState.php", nil)
+	if e == nil {
+		for _, state := range resp {
+			if state.Time != 0 {
+				state.ActivationDeadline = time.Now().Add(time.Duration(state.Time))
+			}
+		}
+	}
+	return
+}
+
+/*
+	Request the next message
+*/
+
+type SetOperationReviseResponse struct {
+	Response StringResponse `json:"response"`
+	// Tzid is the activation id
+	Tzid int `json:"tzid"`
+}
+
+// ApiSetOperationRevise asks the service to wait for one more message on the same number.
+func (p *Provider) ApiSetOperationRevise(ctx context.Context, tzid int) (resp SetOperationReviseResponse, e error) {
+	resp, e = ApiRequest[SetOperationReviseResponse](ctx, p.Ses.R().SetQueryParam("tzid", strconv.Itoa(tzid)), "setOperationRevise.php", []string{"1"})
+	return
+}
+
+/*
+	Activation
+*/
+
+// RequestNextMessage asks the service to wait for one more message on the activation's number.
+func (p *Activation) RequestNextMessage(ctx context.Context) (e error) {
+	_, e = p.api.ApiSetOperationRevise(ctx, p.GetPhoneResponse.Tzid)
+	return
+}
+
+// WatchMessages receives the activation's messages from the provider's shared poller and calls do for every message
+// not seen before (including those received before the call), until do returns false or a non-nil error. All seen
+// messages are stored in Messages.
+func (p *Activation) WatchMessages(ctx context.Context, do func(m Message) (next bool, e error)) (e error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var updates <-chan StateUpdate
+	var next = true
+	var delivered int
+	for e == nil && next {
+		// Messages seen by the previous calls are delivered first
+		for ; delivered < len(p.Messages) && e == nil && next; delivered++ {
+			next, e = do(p.Messages[delivered])
+		}
+		if e != nil || !next {
+			continue
+		}
+
+		if updates == nil {
+			updates = p.api.SubscribeMessages(ctx, p.GetPhoneResponse.Tzid)
+		}
+		u, ok := <-updates
+		if !ok {
+			e = ctx.Err()
+			continue
+		}
+		if e = u.Err; e != nil {
+			continue
+		}
+		p.State = u.State
+
+		for i := len(p.Messages); i < len(u.Messages); i++ {
+			var m = u.Messages[i]
+			m.ReceivedAt = time.Now()
+			p.Messages = append(p.Messages, m)
+		}
+	}
+	return
+}
+
+// GetCodes waits until n codes are received, requesting the next message after every code. Codes are extracted
+// with extractor, which may be nil (sms.DefaultCodePatterns are used then).
+func (p *Activation) GetCodes(ctx context.Context, n int, extractor *sms.CodeExtractor) (codes []string, e error) {
+	if n <= 0 {
+		return
+	}
+	e = p.WatchMessages(ctx, func(m Message) (next bool, e error) {
+		if code, ok := m.Code(extractor); ok {
+			codes = append(codes, code)
+			if len(codes) < n {
+				e = p.RequestNextMessage(ctx)
+			}
+		}
+		return len(codes) < n, e
+	})
+	return
+}
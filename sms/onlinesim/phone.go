@@ -2,7 +2,6 @@ package onlinesim
 
 import (
 	"context"
-	"github.com/k773/utils"
 	"strconv"
 )
 
@@ -12,6 +11,8 @@ type Activation struct {
 
 	GetPhoneResponse *GetPhoneResponse
 	State            *State
+	// Messages contains every message seen by WatchMessages, in the order of receiving
+	Messages []Message
 
 	number string
 }
@@ -23,6 +24,10 @@ func NewActivation(api *Provider, getPhoneResponse *GetPhoneResponse) *Activatio
 	}
 }
 
+func (p *Activation) Id() string {
+	return strconv.Itoa(p.GetPhoneResponse.Tzid)
+}
+
 func (p *Activation) GetMessage(ctx context.Context) (code string, e error) {
 	e = p.BusyStatePolling(ctx, func(state *State) (next bool, e error) {
 		if state.Msg != "" {
@@ -51,21 +56,24 @@ func (p *Activation) GetNumber(ctx context.Context) (number string, e error) {
 	return
 }
 
-// BusyStatePolling polls states from the api until provided func returns false or a non-nil error.
+// BusyStatePolling receives states from the provider's shared poller until provided func returns false or a non-nil error.
 func (p *Activation) BusyStatePolling(ctx context.Context, do func(*State) (next bool, e error)) (e error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var updates = p.api.SubscribeStates(ctx, p.GetPhoneResponse.Tzid)
 	var next = true
 	for e == nil && next {
-		if e = ctx.Err(); e != nil {
+		u, ok := <-updates
+		if !ok {
+			e = ctx.Err()
 			continue
 		}
-		var state *State
-		if state, e = p.GetState(ctx); e != nil {
+		if e = u.Err; e != nil {
 			continue
 		}
-		next, e = do(state)
-		if next && e == nil {
-			e = utils.SleepWithContext(ctx, p.api.BusyPollingInterval)
-		}
+		p.State = u.State
+		next, e = do(u.State)
 	}
 	return
 }
@@ -82,3 +90,15 @@ func (p *Activation) GetState(ctx context.Context) (state *State, e error) {
 	p.State = state
 	return
 }
+
+// Cancel closes the activation; onlinesim refunds the money if no message was received.
+func (p *Activation) Cancel(ctx context.Context) (e error) {
+	_, e = p.api.ApiSetOperationOk(ctx, p.GetPhoneResponse.Tzid)
+	return
+}
+
+// Finish closes the activation.
+func (p *Activation) Finish(ctx context.Context) (e error) {
+	_, e = p.api.ApiSetOperationOk(ctx, p.GetPhoneResponse.Tzid)
+	return
+}
@@ -0,0 +1,174 @@
+package onlinesim
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type StateUpdate struct {
+	State *State
+	// Messages is the full list of the messages received by the activation; it is set only for the subscribers of
+	// SubscribeMessages
+	Messages []Message
+	// Err is set if the states could not be fetched or the activation was not found
+	Err error
+}
+
+// SubscribeStates returns a channel receiving the activation's state right away and then every BusyPollingInterval.
+// The states of all subscribed activations are fetched with a single request per interval. Only the latest update is
+// kept if the receiver is slow. The channel is closed after ctx is done.
+func (p *Provider) SubscribeStates(ctx context.Context, tzid int) <-chan StateUpdate {
+	return p.subscribe(ctx, tzid, false)
+}
+
+// SubscribeMessages is like SubscribeStates, but the updates contain the full list of the messages received by the
+// activation. The states are fetched with ApiGetStatesMessages, so their Msg is empty.
+func (p *Provider) SubscribeMessages(ctx context.Context, tzid int) <-chan StateUpdate {
+	return p.subscribe(ctx, tzid, true)
+}
+
+func (p *Provider) subscribe(ctx context.Context, tzid int, messages bool) <-chan StateUpdate {
+	var ch = make(chan StateUpdate, 1)
+	p.poller.subscribe(p, tzid, ch, messages)
+	context.AfterFunc(ctx, func() {
+		p.poller.unsubscribe(tzid, ch)
+	})
+	return ch
+}
+
+// statePoller is the shared poller of ApiGetStates and ApiGetStatesMessages; it runs only while there is at least one
+// subscriber, making at most one request of each kind per interval.
+type statePoller struct {
+	// subs maps the tzid to the subscribed channels and whether they want the messages
+	subs    map[int]map[chan StateUpdate]bool
+	running bool
+	// wake makes the running poller poll without waiting for the interval to pass
+	wake chan struct{}
+	l    sync.Mutex
+}
+
+func (s *statePoller) subscribe(p *Provider, tzid int, ch chan StateUpdate, messages bool) {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	if s.subs == nil {
+		s.subs = map[int]map[chan StateUpdate]bool{}
+		s.wake = make(chan struct{}, 1)
+	}
+	if s.subs[tzid] == nil {
+		s.subs[tzid] = map[chan StateUpdate]bool{}
+	}
+	s.subs[tzid][ch] = messages
+
+	if !s.running {
+		s.running = true
+		go s.run(p)
+		return
+	}
+	// The new subscriber gets its first update without waiting for the interval
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *statePoller) unsubscribe(tzid int, ch chan StateUpdate) {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	if _, ok := s.subs[tzid][ch]; ok {
+		delete(s.subs[tzid], ch)
+		if len(s.subs[tzid]) == 0 {
+			delete(s.subs, tzid)
+		}
+		close(ch)
+	}
+}
+
+func (s *statePoller) run(p *Provider) {
+	for {
+		s.l.Lock()
+		if len(s.subs) == 0 {
+			s.running = false
+			s.l.Unlock()
+			return
+		}
+		var wantStates, wantMessages = s.wanted()
+		s.l.Unlock()
+
+		var states = map[int]*State{}
+		var statesErr error
+		if wantStates {
+			var resp GetStatesResponse
+			resp, statesErr = p.ApiGetStates(context.Background(), true)
+			for _, state := range resp {
+				states[state.Tzid] = state
+			}
+		}
+		var messages = map[int]*StateMessages{}
+		var messagesErr error
+		if wantMessages {
+			var resp GetStatesMessagesResponse
+			resp, messagesErr = p.ApiGetStatesMessages(context.Background(), 0)
+			for _, state := range resp {
+				messages[state.Tzid] = state
+			}
+		}
+
+		s.l.Lock()
+		for tzid, subs := range s.subs {
+			for ch, withMessages := range subs {
+				var u StateUpdate
+				if withMessages {
+					u.Err = messagesErr
+					if state := messages[tzid]; state != nil {
+						u.State, u.Messages = &state.State, state.Msg
+					}
+				} else {
+					u.State, u.Err = states[tzid], statesErr
+				}
+				if u.Err == nil && u.State == nil {
+					u.Err = ErrorUnexpectedResponse{Response: "no activation found with tzid: " + strconv.Itoa(tzid)}
+				}
+				s.send(ch, u)
+			}
+		}
+		s.l.Unlock()
+
+		var timer = time.NewTimer(p.BusyPollingInterval)
+		select {
+		case <-timer.C:
+		case <-s.wake:
+			timer.Stop()
+		}
+	}
+}
+
+// wanted reports which kinds of the states the subscribers want; must be called with s.l locked
+func (s *statePoller) wanted() (states, messages bool) {
+	for _, subs := range s.subs {
+		for _, withMessages := range subs {
+			if withMessages {
+				messages = true
+			} else {
+				states = true
+			}
+		}
+	}
+	return
+}
+
+// send replaces the pending update, if any; must be called with s.l locked
+func (s *statePoller) send(ch chan StateUpdate, u StateUpdate) {
+	select {
+	case ch <- u:
+	default:
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- u
+	}
+}
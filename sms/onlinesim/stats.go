@@ -0,0 +1,145 @@
+package onlinesim
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"github.com/k773/utils/fixedPoint"
+	"slices"
+	"strconv"
+)
+
+/*
+	Get numbers stats
+*/
+
+type NumbersStatsCountry struct {
+	Name string `json:"name"`
+	// Code is the country's phone prefix, used as the country argument in ApiGetPhone
+	Code     int  `json:"code"`
+	Position int  `json:"position"`
+	Enabled  bool `json:"enabled"`
+	// Services are keyed by "service_" + slug
+	Services map[string]*NumbersStatsService `json:"services"`
+}
+
+type NumbersStatsService struct {
+	Id      int    `json:"id"`
+	Service string `json:"service"`
+	// Slug is the service name used as the service argument in ApiGetPhone
+	Slug string `json:"slug"`
+	// Count is the amount of numbers available
+	Count int `json:"count"`
+	// Price: currency - usd.
+	Price fixedPoint.FP `json:"price"`
+}
+
+// BySlug finds the service by its slug.
+func (c *NumbersStatsCountry) BySlug(slug string) *NumbersStatsService {
+	if service, ok := c.Services["service_"+slug]; ok {
+		return service
+	}
+	for _, service := range c.Services {
+		if service.Slug == slug {
+			return service
+		}
+	}
+	return nil
+}
+
+// ApiGetNumbersStats returns the availability and prices of the services for the country.
+func (p *Provider) ApiGetNumbersStats(ctx context.Context, country int) (resp NumbersStatsCountry, e error) {
+	resp, e = ApiRequest[NumbersStatsCountry](ctx, p.Ses.R().SetQueryParam("country", strconv.Itoa(country)), "getNumbersStats.php", nil)
+	return
+}
+
+// ApiGetNumbersStatsAll returns the availability and prices of the services for every country, keyed by the country name.
+func (p *Provider) ApiGetNumbersStatsAll(ctx context.Context) (resp map[string]*NumbersStatsCountry, e error) {
+	resp, e = ApiRequest[map[string]*NumbersStatsCountry](ctx, p.Ses.R().SetQueryParam("country", "all"), "getNumbersStats.php", nil)
+	return
+}
+
+/*
+	Country selection
+*/
+
+var ErrNoNumbersAvailable = errors.New("no numbers available")
+
+type CountrySelector struct {
+	// Allow limits the selection to the listed countries; empty = every country is allowed.
+	Allow []int
+	// Deny excludes the listed countries.
+	Deny []int
+	// MaxPrice excludes the offers that cost more; 0 = not limited. Currency - usd.
+	MaxPrice fixedPoint.FP
+	// MinCount excludes the offers with less numbers available.
+	// Default: 1
+	MinCount int
+}
+
+type CountryOffer struct {
+	Country int
+	Price   fixedPoint.FP
+	Count   int
+}
+
+// Offers returns the enabled countries matching the selector for the service, cheapest first; countries with the same price
+// are ordered by the amount of numbers available, descending.
+func (s *CountrySelector) Offers(stats map[string]*NumbersStatsCountry, service string) (offers []CountryOffer) {
+	var allow = make(map[int]struct{}, len(s.Allow))
+	for _, country := range s.Allow {
+		allow[country] = struct{}{}
+	}
+	var deny = make(map[int]struct{}, len(s.Deny))
+	for _, country := range s.Deny {
+		deny[country] = struct{}{}
+	}
+	var minCount = max(s.MinCount, 1)
+
+	for _, country := range stats {
+		if !country.Enabled {
+			continue
+		}
+		if _, ok := allow[country.Code]; len(allow) != 0 && !ok {
+			continue
+		}
+		if _, ok := deny[country.Code]; ok {
+			continue
+		}
+
+		var offer = country.BySlug(service)
+		if offer == nil || offer.Count < minCount || (s.MaxPrice != 0 && offer.Price > s.MaxPrice) {
+			continue
+		}
+		offers = append(offers, CountryOffer{Country: country.Code, Price: offer.Price, Count: offer.Count})
+	}
+
+	slices.SortFunc(offers, func(a, b CountryOffer) int {
+		if a.Price != b.Price {
+			return cmp.Compare(a.Price, b.Price)
+		}
+		return cmp.Compare(b.Count, a.Count)
+	})
+	return
+}
+
+// SelectCountry fetches the stats and returns the cheapest country matching the selector.
+func (p *Provider) SelectCountry(ctx context.Context, service string, s *CountrySelector) (offer CountryOffer, e error) {
+	stats, e := p.ApiGetNumbersStatsAll(ctx)
+	if e != nil {
+		return
+	}
+	if offers := s.Offers(stats, service); len(offers) != 0 {
+		return offers[0], nil
+	}
+	return offer, ErrNoNumbersAvailable
+}
+
+// GetCheapestPhone orders the number in the cheapest country matching the selector.
+func (p *Provider) GetCheapestPhone(ctx context.Context, service string, s *CountrySelector) (phone *Activation, e error) {
+	offer, e := p.SelectCountry(ctx, service, s)
+	if e == nil {
+		phone, e = p.GetPhone(ctx, offer.Country, service)
+	}
+	return
+}
@@ -0,0 +1,29 @@
+/*
+	Provider-agnostic interfaces for the sms activation services
+*/
+
+package sms
+
+import (
+	"context"
+	"github.com/k773/utils/fixedPoint"
+)
+
+type Provider interface {
+	// GetActivation orders a number. The format of country and service is provider-specific.
+	GetActivation(ctx context.Context, country, service string) (Activation, error)
+	// GetBalance returns the balance in the provider's currency.
+	GetBalance(ctx context.Context) (fixedPoint.FP, error)
+}
+
+type Activation interface {
+	// Id returns the provider-specific activation id.
+	Id() string
+	GetNumber(ctx context.Context) (number string, e error)
+	// GetMessage waits for the code.
+	GetMessage(ctx context.Context) (code string, e error)
+	// Cancel cancels the activation; the money is returned if no code was received.
+	Cancel(ctx context.Context) error
+	// Finish marks the activation as successfully completed.
+	Finish(ctx context.Context) error
+}
@@ -0,0 +1,63 @@
+package smsactivate
+
+import (
+	"context"
+	"github.com/k773/utils"
+)
+
+type Activation struct {
+	api *Provider
+
+	ActivationId string
+	Number       string
+}
+
+func (a *Activation) Id() string {
+	return a.ActivationId
+}
+
+func (a *Activation) GetNumber(ctx context.Context) (number string, e error) {
+	return a.Number, nil
+}
+
+func (a *Activation) GetMessage(ctx context.Context) (code string, e error) {
+	e = a.BusyStatusPolling(ctx, func(status, c string) (next bool, e error) {
+		switch status {
+		case "STATUS_OK":
+			code = c
+		case "STATUS_CANCEL":
+			e = ErrorUnexpectedResponse{Response: status}
+		}
+		return code == "", e
+	})
+	return
+}
+
+// BusyStatusPolling polls the status from the api until provided func returns false or a non-nil error.
+func (a *Activation) BusyStatusPolling(ctx context.Context, do func(status, code string) (next bool, e error)) (e error) {
+	var next = true
+	for e == nil && next {
+		if e = ctx.Err(); e != nil {
+			continue
+		}
+		var status, code string
+		if status, code, e = a.api.ApiGetStatus(ctx, a.ActivationId); e != nil {
+			continue
+		}
+		next, e = do(status, code)
+		if next && e == nil {
+			e = utils.SleepWithContext(ctx, a.api.BusyPollingInterval)
+		}
+	}
+	return
+}
+
+func (a *Activation) Cancel(ctx context.Context) (e error) {
+	_, e = a.api.ApiSetStatus(ctx, a.ActivationId, StatusCancel)
+	return
+}
+
+func (a *Activation) Finish(ctx context.Context) (e error) {
+	_, e = a.api.ApiSetStatus(ctx, a.ActivationId, StatusFinish)
+	return
+}
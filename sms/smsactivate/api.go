@@ -0,0 +1,144 @@
+package smsactivate
+
+import (
+	"context"
+	"github.com/go-resty/resty/v2"
+	"github.com/k773/utils/fixedPoint"
+	"github.com/k773/utils/sms"
+	"strings"
+	"time"
+)
+
+const apiEndpoint = "https://api.sms-activate.org/stubs/handler_api.php"
+
+type Provider struct {
+	Ses *resty.Client
+	// BusyPollingInterval is used by the methods that are continuously polling data from the api.
+	// Default: 5sec.
+	BusyPollingInterval time.Duration
+}
+
+func New(key string) *Provider {
+	var ses = resty.New()
+	ses.OnBeforeRequest(func(client *resty.Client, request *resty.Request) error {
+		request.SetQueryParam("api_key", key)
+		return nil
+	})
+	return &Provider{Ses: ses, BusyPollingInterval: 5 * time.Second}
+}
+
+/*
+	Get balance
+*/
+
+// GetBalance implements sms.Provider; the balance is in rub.
+func (p *Provider) GetBalance(ctx context.Context) (balance fixedPoint.FP, e error) {
+	fields, e := p.ApiRequest(ctx, p.Ses.R().SetQueryParam("action", "getBalance"), "ACCESS_BALANCE")
+	if e == nil && len(fields) != 2 {
+		e = ErrorUnexpectedResponse{Response: strings.Join(fields, ":")}
+	}
+	if e == nil {
+		balance = fixedPoint.Parse(fields[1])
+	}
+	return
+}
+
+/*
+	Get number
+*/
+
+// GetActivation implements sms.Provider. For documentation see GetPhone.
+func (p *Provider) GetActivation(ctx context.Context, country, service string) (activation sms.Activation, e error) {
+	phone, e := p.GetPhone(ctx, country, service)
+	if e == nil {
+		activation = phone
+	}
+	return
+}
+
+// GetPhone orders the phone for the given service.
+// Arguments:
+// country - sms-activate's country id: 0 - russia, 1 - ukraine, ... (all available: https://sms-activate.org/api2#getCountries)
+// service - sms-activate's service code: go - google, vk - vkcom, ...
+func (p *Provider) GetPhone(ctx context.Context, country, service string) (phone *Activation, e error) {
+	fields, e := p.ApiRequest(ctx, p.Ses.R().
+		SetQueryParam("action", "getNumber").
+		SetQueryParam("country", country).
+		SetQueryParam("service", service), "ACCESS_NUMBER")
+	if e == nil && len(fields) != 3 {
+		e = ErrorUnexpectedResponse{Response: strings.Join(fields, ":")}
+	}
+	if e == nil {
+		phone = &Activation{api: p, ActivationId: fields[1], Number: fields[2]}
+	}
+	return
+}
+
+/*
+	Set status
+*/
+
+type Status string
+
+const (
+	// StatusReady notifies the service that the sms was sent
+	StatusReady Status = "1"
+	// StatusRetry requests one more code on the same number
+	StatusRetry Status = "3"
+	// StatusFinish completes the activation
+	StatusFinish Status = "6"
+	// StatusCancel cancels the activation
+	StatusCancel Status = "8"
+)
+
+func (p *Provider) ApiSetStatus(ctx context.Context, id string, status Status) (response string, e error) {
+	fields, e := p.ApiRequest(ctx, p.Ses.R().
+		SetQueryParam("action", "setStatus").
+		SetQueryParam("id", id).
+		SetQueryParam("status", string(status)), "ACCESS_READY", "ACCESS_RETRY_GET", "ACCESS_ACTIVATION", "ACCESS_CANCEL")
+	if e == nil {
+		response = fields[0]
+	}
+	return
+}
+
+/*
+	Get status
+*/
+
+// ApiGetStatus returns the activation status and the code, if received.
+// Statuses: STATUS_WAIT_CODE, STATUS_WAIT_RETRY, STATUS_WAIT_RESEND, STATUS_CANCEL, STATUS_OK.
+func (p *Provider) ApiGetStatus(ctx context.Context, id string) (status, code string, e error) {
+	fields, e := p.ApiRequest(ctx, p.Ses.R().
+		SetQueryParam("action", "getStatus").
+		SetQueryParam("id", id), "STATUS_WAIT_CODE", "STATUS_WAIT_RETRY", "STATUS_WAIT_RESEND", "STATUS_CANCEL", "STATUS_OK")
+	if e == nil {
+		status = fields[0]
+		if len(fields) > 1 {
+			code = strings.Join(fields[1:], ":")
+		}
+	}
+	return
+}
+
+/*
+	Generic api
+*/
+
+// ApiRequest executes provided request in the provided context and splits the plain-text response by ':'.
+// The first field of the response must be one of allowedResponses.
+func (p *Provider) ApiRequest(ctx context.Context, req *resty.Request, allowedResponses ...string) (fields []string, e error) {
+	r, e := req.SetContext(ctx).Get(apiEndpoint)
+	if e != nil {
+		return
+	}
+
+	var body = strings.TrimSpace(r.String())
+	fields = strings.Split(body, ":")
+	for _, v := range allowedResponses {
+		if fields[0] == v {
+			return
+		}
+	}
+	return nil, ErrorUnexpectedResponse{Response: body}
+}
@@ -0,0 +1,89 @@
+package store
+
+import "sort"
+
+const minRingSize = 8
+
+// EventsRing is a ring buffer of events ordered by their time. Adding an event
+// and removing the oldest ones is O(1) amortised, counting the events of a
+// time range is O(log n).
+// The zero value is ready to use.
+type EventsRing[T any] struct {
+	buf  []EventWrapper[T]
+	head int // index of the oldest event
+	n    int
+}
+
+func (r *EventsRing[T]) Len() int {
+	return r.n
+}
+
+// At returns the i-th oldest event.
+func (r *EventsRing[T]) At(i int) EventWrapper[T] {
+	return r.buf[(r.head+i)%len(r.buf)]
+}
+
+// Push adds the event as the newest one. An event older than the newest one
+// gets its time, so the order is kept if the wall clock goes backwards.
+func (r *EventsRing[T]) Push(event EventWrapper[T]) {
+	if r.n != 0 {
+		event.EventTime = max(event.EventTime, r.At(r.n-1).EventTime)
+	}
+	if r.n == len(r.buf) {
+		r.resize(max(2*len(r.buf), minRingSize))
+	}
+	r.buf[(r.head+r.n)%len(r.buf)] = event
+	r.n++
+}
+
+// PopBefore removes the events older than the time and returns their amount.
+func (r *EventsRing[T]) PopBefore(t int64) (n int) {
+	var zero EventWrapper[T]
+	for r.n != 0 && r.buf[r.head].EventTime < t {
+		r.buf[r.head] = zero
+		r.head = (r.head + 1) % len(r.buf)
+		r.n--
+		n++
+	}
+	if r.n == 0 {
+		r.head = 0
+	}
+	if len(r.buf) > minRingSize && r.n < len(r.buf)/4 {
+		r.resize(len(r.buf) / 2)
+	}
+	return
+}
+
+// search returns the index of the oldest event not older than the time.
+func (r *EventsRing[T]) search(t int64) int {
+	return sort.Search(r.n, func(i int) bool {
+		return r.At(i).EventTime >= t
+	})
+}
+
+// CountSince returns the amount of the events not older than the time.
+func (r *EventsRing[T]) CountSince(t int64) int {
+	return r.n - r.search(t)
+}
+
+// CountBetween returns the amount of the events in [from, to).
+func (r *EventsRing[T]) CountBetween(from, to int64) int {
+	return max(r.search(to)-r.search(from), 0)
+}
+
+// Slice returns a copy of the events, the oldest first.
+func (r *EventsRing[T]) Slice() []EventWrapper[T] {
+	res := make([]EventWrapper[T], r.n)
+	for i := range res {
+		res[i] = r.At(i)
+	}
+	return res
+}
+
+func (r *EventsRing[T]) resize(size int) {
+	buf := make([]EventWrapper[T], size)
+	for i := 0; i < r.n; i++ {
+		buf[i] = r.At(i)
+	}
+	r.buf, r.head = buf, 0
+}
@@ -0,0 +1,98 @@
+package store
+
+import (
+	"math"
+	"sort"
+)
+
+// sketchAccuracy is the relative accuracy of the quantiles.
+const sketchAccuracy = 0.01
+
+var sketchLogGamma = math.Log((1 + sketchAccuracy) / (1 - sketchAccuracy))
+
+// quantileSketch is a mergeable histogram of the values with the logarithmic
+// bins (DDSketch): a quantile is returned with the relative error of
+// sketchAccuracy.
+type quantileSketch struct {
+	pos, neg map[int]uint64 // bin index -> count
+	zero     uint64
+	count    uint64
+}
+
+func newQuantileSketch() *quantileSketch {
+	return &quantileSketch{pos: map[int]uint64{}, neg: map[int]uint64{}}
+}
+
+func sketchIndex(v float64) int {
+	return int(math.Ceil(math.Log(v) / sketchLogGamma))
+}
+
+// sketchValue returns the value representing the bin.
+func sketchValue(i int) float64 {
+	gamma := math.Exp(sketchLogGamma)
+	return 2 * math.Pow(gamma, float64(i)) / (gamma + 1)
+}
+
+func (s *quantileSketch) add(v float64) {
+	switch {
+	case v > 0:
+		s.pos[sketchIndex(v)]++
+	case v < 0:
+		s.neg[sketchIndex(-v)]++
+	default:
+		s.zero++
+	}
+	s.count++
+}
+
+func (s *quantileSketch) merge(o *quantileSketch) {
+	for i, n := range o.pos {
+		s.pos[i] += n
+	}
+	for i, n := range o.neg {
+		s.neg[i] += n
+	}
+	s.zero += o.zero
+	s.count += o.count
+}
+
+func (s *quantileSketch) clone() *quantileSketch {
+	res := newQuantileSketch()
+	res.merge(s)
+	return res
+}
+
+// quantile returns the q-quantile, q in [0, 1].
+func (s *quantileSketch) quantile(q float64) float64 {
+	if s.count == 0 {
+		return math.NaN()
+	}
+	rank := uint64(q * float64(s.count-1))
+
+	var seen uint64
+	negIdx := sortedKeys(s.neg)
+	for i := len(negIdx) - 1; i >= 0; i-- {
+		if seen += s.neg[negIdx[i]]; seen > rank {
+			return -sketchValue(negIdx[i])
+		}
+	}
+	if seen += s.zero; seen > rank {
+		return 0
+	}
+	posIdx := sortedKeys(s.pos)
+	for _, i := range posIdx {
+		if seen += s.pos[i]; seen > rank {
+			return sketchValue(i)
+		}
+	}
+	return sketchValue(posIdx[len(posIdx)-1])
+}
+
+func sortedKeys(m map[int]uint64) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
@@ -0,0 +1,256 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/gob"
+	"errors"
+	"github.com/k773/utils/files"
+	"math"
+	"os"
+	"time"
+)
+
+// Snapshotter is implemented by the stores. The snapshots are gob encoded, so
+// the event types must be encodable by gob; the interface event types have to
+// be registered with gob.Register.
+type Snapshotter interface {
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+}
+
+// SaveSnapshot writes the snapshot of s to the file atomically.
+func SaveSnapshot(path string, s Snapshotter) error {
+	data, e := s.MarshalBinary()
+	if e != nil {
+		return e
+	}
+	return files.WriteFileAtomic(path, data, 0644)
+}
+
+// LoadSnapshot restores s from the file. A missing file is not an error.
+func LoadSnapshot(path string, s Snapshotter) error {
+	data, e := os.ReadFile(path)
+	if errors.Is(e, os.ErrNotExist) {
+		return nil
+	} else if e != nil {
+		return e
+	}
+	return s.UnmarshalBinary(data)
+}
+
+// RunSnapshots saves the snapshots of s to the file in an indefinite loop and once more when ctx is done. Ticker
+// will be stopped when ctx is done. onError may be nil.
+func RunSnapshots(ctx context.Context, s Snapshotter, path string, ticker *time.Ticker, onError func(e error)) {
+	defer ticker.Stop()
+
+	var save = func() {
+		if e := SaveSnapshot(path, s); e != nil && onError != nil {
+			onError(e)
+		}
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			save()
+			return
+		case <-ticker.C:
+			save()
+		}
+	}
+}
+
+func gobEncode(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	e := gob.NewEncoder(&buf).Encode(v)
+	return buf.Bytes(), e
+}
+
+func gobDecode(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// ringFromSlice returns the ring of the events which are not older than the
+// time.
+func ringFromSlice[T any](events []EventWrapper[T], since int64) *EventsRing[T] {
+	var r EventsRing[T]
+	for _, ev := range events {
+		if ev.EventTime >= since {
+			r.Push(ev)
+		}
+	}
+	return &r
+}
+
+/*
+	TimedEventsStore
+*/
+
+func (t *TimedEventsStore[EventT]) MarshalBinary() ([]byte, error) {
+	t.s.Lock()
+	defer t.s.Unlock()
+
+	return gobEncode(t.Events.Slice())
+}
+
+// UnmarshalBinary replaces the events by the snapshot ones, dropping the
+// expired events.
+func (t *TimedEventsStore[EventT]) UnmarshalBinary(data []byte) error {
+	var events []EventWrapper[EventT]
+	if e := gobDecode(data, &events); e != nil {
+		return e
+	}
+
+	t.s.Lock()
+	defer t.s.Unlock()
+	t.Events = *ringFromSlice(events, time.Now().UnixNano()-t.lifetime)
+	return nil
+}
+
+/*
+	TimedMultiEventsStoreL2
+*/
+
+func (t *TimedMultiEventsStoreL2[K, EventT]) MarshalBinary() ([]byte, error) {
+	t.s.Lock()
+	defer t.s.Unlock()
+
+	var snapshot = make(map[K][]EventWrapper[EventT], len(t.Events))
+	for k, v := range t.Events {
+		snapshot[k] = v.Slice()
+	}
+	return gobEncode(snapshot)
+}
+
+// UnmarshalBinary replaces the events by the snapshot ones, dropping the
+// expired events and the keys left without events.
+func (t *TimedMultiEventsStoreL2[K, EventT]) UnmarshalBinary(data []byte) error {
+	var snapshot map[K][]EventWrapper[EventT]
+	if e := gobDecode(data, &snapshot); e != nil {
+		return e
+	}
+
+	t.s.Lock()
+	defer t.s.Unlock()
+	var since = time.Now().UnixNano() - t.lifetime
+	t.Events = map[K]*EventsRing[EventT]{}
+	for k, events := range snapshot {
+		if r := ringFromSlice(events, since); r.Len() != 0 {
+			t.Events[k] = r
+		}
+	}
+	return nil
+}
+
+/*
+	TimedMultiEventsStoreL3
+*/
+
+func (t *TimedMultiEventsStoreL3[KL3, KL2, EventT]) MarshalBinary() ([]byte, error) {
+	t.s.Lock()
+	defer t.s.Unlock()
+
+	var snapshot = make(map[KL3]map[KL2][]EventWrapper[EventT], len(t.Events))
+	for subKey, mL3 := range t.Events {
+		snapshot[subKey] = make(map[KL2][]EventWrapper[EventT], len(mL3))
+		for key, v := range mL3 {
+			snapshot[subKey][key] = v.Slice()
+		}
+	}
+	return gobEncode(snapshot)
+}
+
+// UnmarshalBinary replaces the events by the snapshot ones, dropping the
+// expired events and the keys left without events.
+func (t *TimedMultiEventsStoreL3[KL3, KL2, EventT]) UnmarshalBinary(data []byte) error {
+	var snapshot map[KL3]map[KL2][]EventWrapper[EventT]
+	if e := gobDecode(data, &snapshot); e != nil {
+		return e
+	}
+
+	t.s.Lock()
+	defer t.s.Unlock()
+	var since = time.Now().UnixNano() - t.lifetime
+	t.Events = map[KL3]map[KL2]*EventsRing[EventT]{}
+	for subKey, mL3 := range snapshot {
+		for key, events := range mL3 {
+			if r := ringFromSlice(events, since); r.Len() != 0 {
+				if t.Events[subKey] == nil {
+					t.Events[subKey] = map[KL2]*EventsRing[EventT]{}
+				}
+				t.Events[subKey][key] = r
+			}
+		}
+	}
+	return nil
+}
+
+/*
+	TimeSeriesStore
+*/
+
+type aggregateSnapshot struct {
+	Start         int64 // nanos
+	Count         int64
+	Sum, Min, Max float64
+	Pos, Neg      map[int]uint64
+	Zero          uint64
+}
+
+func (t *TimeSeriesStore[K]) MarshalBinary() ([]byte, error) {
+	var snapshot = make(map[K][]aggregateSnapshot)
+	for _, key := range t.Keys() {
+		// The closed buckets are immutable, but the last one is copied.
+		for _, b := range t.buckets(time.Now().UnixNano(), key, math.MaxInt64) {
+			snapshot[key] = append(snapshot[key], aggregateSnapshot{
+				Start: b.Start.UnixNano(),
+				Count: b.Count,
+				Sum:   b.Sum,
+				Min:   b.Min,
+				Max:   b.Max,
+				Pos:   b.sketch.pos,
+				Neg:   b.sketch.neg,
+				Zero:  b.sketch.zero,
+			})
+		}
+	}
+	return gobEncode(snapshot)
+}
+
+// UnmarshalBinary replaces the buckets by the snapshot ones, dropping the
+// expired buckets. The snapshot must be made with the same resolution.
+func (t *TimeSeriesStore[K]) UnmarshalBinary(data []byte) error {
+	var snapshot map[K][]aggregateSnapshot
+	if e := gobDecode(data, &snapshot); e != nil {
+		return e
+	}
+
+	var since = time.Now().UnixNano() - t.retention
+	var series = map[K]*timeSeries{}
+	for key, buckets := range snapshot {
+		var v = new(timeSeries)
+		for _, b := range buckets {
+			if b.Start < since {
+				continue
+			}
+			var a = newAggregate(time.Unix(0, b.Start))
+			a.Count, a.Sum, a.Min, a.Max = b.Count, b.Sum, b.Min, b.Max
+			a.sketch.merge(&quantileSketch{pos: b.Pos, neg: b.Neg, zero: b.Zero, count: uint64(b.Count)})
+			v.buckets.Push(EventWrapper[*Aggregate]{EventTime: b.Start, Event: a})
+		}
+		if v.buckets.Len() != 0 {
+			series[key] = v
+		}
+	}
+
+	t.s.Lock()
+	defer t.s.Unlock()
+	for _, v := range t.series {
+		v.s.Lock()
+		v.removed = true
+		v.s.Unlock()
+	}
+	t.series = series
+	return nil
+}
@@ -0,0 +1,230 @@
+package store
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Aggregate is the aggregate of the values added during a time range.
+type Aggregate struct {
+	// Start is the start of the range.
+	Start         time.Time
+	Count         int64
+	Sum, Min, Max float64
+
+	sketch *quantileSketch
+}
+
+func newAggregate(start time.Time) *Aggregate {
+	return &Aggregate{Start: start, Min: math.Inf(1), Max: math.Inf(-1), sketch: newQuantileSketch()}
+}
+
+func (a *Aggregate) add(v float64) {
+	a.Count++
+	a.Sum += v
+	a.Min = min(a.Min, v)
+	a.Max = max(a.Max, v)
+	a.sketch.add(v)
+}
+
+func (a *Aggregate) merge(o *Aggregate) {
+	a.Count += o.Count
+	a.Sum += o.Sum
+	a.Min = min(a.Min, o.Min)
+	a.Max = max(a.Max, o.Max)
+	a.sketch.merge(o.sketch)
+}
+
+func (a *Aggregate) clone() *Aggregate {
+	res := *a
+	res.sketch = a.sketch.clone()
+	return &res
+}
+
+// Avg returns the average value, NaN if there are no values.
+func (a *Aggregate) Avg() float64 {
+	if a.Count == 0 {
+		return math.NaN()
+	}
+	return a.Sum / float64(a.Count)
+}
+
+// Quantile returns the q-quantile of the values (e.g. 0.99 for p99) with 1%
+// relative error, NaN if there are no values.
+func (a *Aggregate) Quantile(q float64) float64 {
+	if a.Count == 0 {
+		return math.NaN()
+	}
+	return max(min(a.sketch.quantile(max(min(q, 1), 0)), a.Max), a.Min)
+}
+
+// TimeSeriesStore aggregates the values added per key in time buckets of the
+// resolution, kept for the retention.
+// Only the bucket being filled is locked by the queries, and only to copy it.
+type TimeSeriesStore[K comparable] struct {
+	s          sync.RWMutex
+	series     map[K]*timeSeries
+	resolution int64 // nanoseconds
+	retention  int64 // nanoseconds
+}
+
+type timeSeries struct {
+	s sync.Mutex
+	// buckets are ordered by their start; only the last one is modified.
+	buckets EventsRing[*Aggregate]
+	// removed is set when the series is removed from the store by clean.
+	removed bool
+}
+
+func NewTimeSeriesStore[K comparable](resolution, retention time.Duration) *TimeSeriesStore[K] {
+	return &TimeSeriesStore[K]{
+		series:     map[K]*timeSeries{},
+		resolution: max(resolution.Nanoseconds(), 1),
+		retention:  retention.Nanoseconds(),
+	}
+}
+
+func (t *TimeSeriesStore[K]) get(key K, create bool) *timeSeries {
+	t.s.RLock()
+	v := t.series[key]
+	t.s.RUnlock()
+	if v != nil || !create {
+		return v
+	}
+
+	t.s.Lock()
+	defer t.s.Unlock()
+	if v = t.series[key]; v == nil {
+		v = new(timeSeries)
+		t.series[key] = v
+	}
+	return v
+}
+
+func (t *TimeSeriesStore[K]) Add(key K, value float64) {
+	t.add(time.Now().UnixNano(), key, value)
+}
+
+func (t *TimeSeriesStore[K]) add(now int64, key K, value float64) {
+	for {
+		v := t.get(key, true)
+		v.s.Lock()
+		// The series is removed concurrently by clean if it is empty.
+		if !v.removed {
+			v.add(now, t.resolution, t.retention, value)
+			v.s.Unlock()
+			return
+		}
+		v.s.Unlock()
+	}
+}
+
+// add must be called with v.s held.
+func (v *timeSeries) add(now, resolution, retention int64, value float64) {
+	v.buckets.PopBefore(now - retention)
+	start := now - now%resolution
+	if n := v.buckets.Len(); n == 0 || v.buckets.At(n-1).EventTime < start {
+		v.buckets.Push(EventWrapper[*Aggregate]{EventTime: start, Event: newAggregate(time.Unix(0, start))})
+	}
+	v.buckets.At(v.buckets.Len() - 1).Event.add(value)
+}
+
+// buckets returns the buckets of the key overlapping the last window; the last
+// one is a copy.
+func (t *TimeSeriesStore[K]) buckets(now int64, key K, window time.Duration) (res []*Aggregate) {
+	v := t.get(key, false)
+	if v == nil {
+		return nil
+	}
+	v.s.Lock()
+	defer v.s.Unlock()
+
+	since := max(now-window.Nanoseconds(), now-t.retention)
+	for i := v.buckets.search(since - since%t.resolution); i < v.buckets.Len(); i++ {
+		res = append(res, v.buckets.At(i).Event)
+	}
+	if n := len(res); n != 0 {
+		res[n-1] = res[n-1].clone()
+	}
+	return res
+}
+
+// Aggregate returns the aggregate of the key's values added during the last
+// window, rounded up to the resolution.
+func (t *TimeSeriesStore[K]) Aggregate(key K, window time.Duration) *Aggregate {
+	now := time.Now().UnixNano()
+	since := now - window.Nanoseconds()
+	res := newAggregate(time.Unix(0, since-since%t.resolution))
+	for _, b := range t.buckets(now, key, window) {
+		res.merge(b)
+	}
+	return res
+}
+
+// Buckets returns the aggregates of the key's buckets overlapping the last
+// window, the oldest first. The buckets having no values are omitted.
+func (t *TimeSeriesStore[K]) Buckets(key K, window time.Duration) []*Aggregate {
+	res := t.buckets(time.Now().UnixNano(), key, window)
+	for i, b := range res[:max(len(res)-1, 0)] {
+		res[i] = b.clone()
+	}
+	return res
+}
+
+func (t *TimeSeriesStore[K]) Keys() []K {
+	t.s.RLock()
+	defer t.s.RUnlock()
+
+	keys := make([]K, 0, len(t.series))
+	for k := range t.series {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// Run removes the expired buckets and the keys having no buckets in an indefinite loop. Call is not mandatory. Ticker will be stopped when ctx is done.
+func (t *TimeSeriesStore[K]) Run(ctx context.Context, ticker *time.Ticker) {
+	defer ticker.Stop()
+loop:
+	for now := range ticker.C {
+		select {
+		case <-ctx.Done():
+			break loop
+		default:
+			t.clean(now.UnixNano())
+		}
+	}
+}
+
+func (t *TimeSeriesStore[K]) clean(now int64) {
+	var empty []K
+	for _, key := range t.Keys() {
+		v := t.get(key, false)
+		if v == nil {
+			continue
+		}
+		v.s.Lock()
+		if v.buckets.PopBefore(now - t.retention); v.buckets.Len() == 0 {
+			empty = append(empty, key)
+		}
+		v.s.Unlock()
+	}
+	if len(empty) == 0 {
+		return
+	}
+
+	t.s.Lock()
+	defer t.s.Unlock()
+	for _, key := range empty {
+		if v := t.series[key]; v != nil {
+			v.s.Lock()
+			if v.buckets.Len() == 0 {
+				v.removed = true
+				delete(t.series, key)
+			}
+			v.s.Unlock()
+		}
+	}
+}
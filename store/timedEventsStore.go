@@ -9,28 +9,23 @@ import (
 // TimedEventsStore stores events for a specified amount of time
 type TimedEventsStore[EventT any] struct {
 	s        sync.Mutex
-	Events   map[int64]EventWrapper[EventT] // key - event expiration in unix nanos
-	lifetime int64                          // nanoseconds
+	Events   EventsRing[EventT] // ordered by the event time
+	lifetime int64              // nanoseconds
 }
 
 func NewTimedEventsStore[T any](lifetime time.Duration) *TimedEventsStore[T] {
 	return &TimedEventsStore[T]{
-		Events:   map[int64]EventWrapper[T]{},
 		lifetime: lifetime.Nanoseconds(),
 	}
 }
 
 func (t *TimedEventsStore[EventT]) add(now int64, event EventT) {
 	t.clean(now)
-	t.Events[now+t.lifetime] = EventWrapper[EventT]{EventTime: now, Event: event}
+	t.Events.Push(EventWrapper[EventT]{EventTime: now, Event: event})
 }
 
 func (t *TimedEventsStore[EventT]) clean(now int64) {
-	for expiration := range t.Events {
-		if now > expiration {
-			delete(t.Events, now)
-		}
-	}
+	t.Events.PopBefore(now - t.lifetime)
 }
 
 // Tools
@@ -45,7 +40,7 @@ loop:
 			break loop
 		default:
 			t.s.Lock()
-			t.clean(now.UnixMilli())
+			t.clean(now.UnixNano())
 			t.s.Unlock()
 		}
 	}
@@ -60,7 +55,31 @@ func (t *TimedEventsStore[EventT]) Count(externalLock bool) (count int) {
 	}
 
 	t.clean(time.Now().UnixNano())
-	return len(t.Events)
+	return t.Events.Len()
+}
+
+// CountWindow returns the amount of the events added during the last window,
+// which should not exceed the lifetime.
+func (t *TimedEventsStore[EventT]) CountWindow(externalLock bool, window time.Duration) (count int) {
+	if !externalLock {
+		t.s.Lock()
+		defer t.s.Unlock()
+	}
+
+	var now = time.Now().UnixNano()
+	t.clean(now)
+	return t.Events.CountSince(now - window.Nanoseconds())
+}
+
+// CountBetween returns the amount of the events added in [from, to).
+func (t *TimedEventsStore[EventT]) CountBetween(externalLock bool, from, to time.Time) (count int) {
+	if !externalLock {
+		t.s.Lock()
+		defer t.s.Unlock()
+	}
+
+	t.clean(time.Now().UnixNano())
+	return t.Events.CountBetween(from.UnixNano(), to.UnixNano())
 }
 
 func (t *TimedEventsStore[EventT]) Add(externalLock bool, event EventT) {
@@ -9,8 +9,8 @@ import (
 // TimedMultiEventsStoreL2 stores multiple events of different subtypes for a specified amount of time
 type TimedMultiEventsStoreL2[K comparable, EventT any] struct {
 	s        sync.Mutex
-	Events   map[K]map[int64]EventWrapper[EventT] // event subtype (L2) -> event expiration in unix nanos -> event
-	lifetime int64                                // nanoseconds
+	Events   map[K]*EventsRing[EventT] // event subtype (L2) -> events ordered by the event time
+	lifetime int64                     // nanoseconds
 
 	DisableChecksOnInserts   bool
 	DisableChecksOnRetrieves bool
@@ -18,7 +18,7 @@ type TimedMultiEventsStoreL2[K comparable, EventT any] struct {
 
 func NewTimedMultiEventsStore[K comparable, T any](lifetime time.Duration) *TimedMultiEventsStoreL2[K, T] {
 	return &TimedMultiEventsStoreL2[K, T]{
-		Events:   map[K]map[int64]EventWrapper[T]{},
+		Events:   map[K]*EventsRing[T]{},
 		lifetime: lifetime.Nanoseconds(),
 	}
 }
@@ -28,12 +28,23 @@ func (t *TimedMultiEventsStoreL2[K, EventT]) count(key K, deleteMapWhenEmpty boo
 		t.clean(time.Now().UnixNano(), key, deleteMapWhenEmpty)
 	}
 	if v, h := t.Events[key]; h {
-		return len(v)
+		return v.Len()
 	} else {
 		return 0
 	}
 }
 
+func (t *TimedMultiEventsStoreL2[K, EventT]) countWindow(key K, window time.Duration, deleteMapWhenEmpty bool) (count int) {
+	var now = time.Now().UnixNano()
+	if !t.DisableChecksOnRetrieves {
+		t.clean(now, key, deleteMapWhenEmpty)
+	}
+	if v, h := t.Events[key]; h {
+		return v.CountSince(now - window.Nanoseconds())
+	}
+	return 0
+}
+
 // has returns whether the key exists in the store. Doesn't check for a 2lvl map length != 0. In you need to, you need count() != 0
 func (t *TimedMultiEventsStoreL2[K, EventT]) has(key K, deleteMapWhenEmpty bool) (has bool) {
 	if !t.DisableChecksOnRetrieves {
@@ -44,29 +55,24 @@ func (t *TimedMultiEventsStoreL2[K, EventT]) has(key K, deleteMapWhenEmpty bool)
 }
 
 func (t *TimedMultiEventsStoreL2[K, EventT]) add(now int64, key K, event EventT) {
-	if !t.DisableChecksOnRetrieves {
+	if !t.DisableChecksOnInserts {
 		t.clean(now, key, false)
 	}
 	v, h := t.Events[key]
 	if !h {
-		v = map[int64]EventWrapper[EventT]{}
+		v = new(EventsRing[EventT])
 		t.Events[key] = v
 	}
-	v[now+t.lifetime] = EventWrapper[EventT]{EventTime: now, Event: event}
+	v.Push(EventWrapper[EventT]{EventTime: now, Event: event})
 }
 
 func (t *TimedMultiEventsStoreL2[K, EventT]) clean(now int64, key K, deleteMap bool) {
-	var valid bool
-	for expiration := range t.Events[key] {
-		if now > expiration {
-			delete(t.Events[key], now)
-		} else if !valid {
-			valid = true
+	if v, h := t.Events[key]; h {
+		v.PopBefore(now - t.lifetime)
+		if deleteMap && v.Len() == 0 {
+			delete(t.Events, key)
 		}
 	}
-	if deleteMap && !valid {
-		delete(t.Events, key)
-	}
 }
 
 // Tools
@@ -81,7 +87,7 @@ loop:
 		case <-ctx.Done():
 			break loop
 		default:
-			var now = now.UnixMilli()
+			var now = now.UnixNano()
 			t.s.Lock()
 			for k := range t.Events {
 				t.clean(now, k, deleteMapWhenEmpty)
@@ -104,6 +110,18 @@ func (t *TimedMultiEventsStoreL2[K, EventT]) Count(externalLock bool, key K, del
 	return t.count(key, deleteMapWhenEmpty)
 }
 
+// CountWindow returns the amount of the key's events added during the last
+// window, which should not exceed the lifetime.
+// deleteMapWhenEmpty specifies whether the sub-map should be deleted when it's empty.
+func (t *TimedMultiEventsStoreL2[K, EventT]) CountWindow(externalLock bool, key K, window time.Duration, deleteMapWhenEmpty bool) (count int) {
+	if !externalLock {
+		t.s.Lock()
+		defer t.s.Unlock()
+	}
+
+	return t.countWindow(key, window, deleteMapWhenEmpty)
+}
+
 // Has returns whether the key exists in the store regardless of its content length.
 // deleteMapWhenEmpty specifies whether the sub-map should be deleted when it's empty.
 func (t *TimedMultiEventsStoreL2[K, EventT]) Has(externalLock bool, key K, deleteMapWhenEmpty bool) (has bool) {
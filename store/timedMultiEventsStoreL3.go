@@ -9,27 +9,39 @@ import (
 // TimedMultiEventsStoreL3 stores multiple events of different subtypes and sub-subtypes for a specified amount of time
 type TimedMultiEventsStoreL3[KL3, KL2 comparable, EventT any] struct {
 	s        sync.Mutex
-	Events   map[KL3]map[KL2]map[int64]EventWrapper[EventT] // event sub-subtype (L3) -> event subtype (L2) -> event expiration in unix nanos (l1) -> event
-	lifetime int64                                          // nanoseconds
+	Events   map[KL3]map[KL2]*EventsRing[EventT] // event sub-subtype (L3) -> event subtype (L2) -> events ordered by the event time (l1)
+	lifetime int64                               // nanoseconds
 
-	DisableChecksOnInserts bool
-	//DisableChecksOnRetrieves bool
+	DisableChecksOnInserts   bool
+	DisableChecksOnRetrieves bool
 }
 
 func NewTimedMultiEventsStoreL3[KL3, KL2 comparable, T any](lifetime time.Duration) *TimedMultiEventsStoreL3[KL3, KL2, T] {
 	return &TimedMultiEventsStoreL3[KL3, KL2, T]{
-		Events:   map[KL3]map[KL2]map[int64]EventWrapper[T]{},
+		Events:   map[KL3]map[KL2]*EventsRing[T]{},
 		lifetime: lifetime.Nanoseconds(),
 	}
 }
 
 func (t *TimedMultiEventsStoreL3[KL3, KL2, EventT]) count(now int64, subkey KL3, key KL2, deleteMapWhenEmpty bool) (count int) {
-	//if !t.DisableChecksOnRetrieves {
-	//	t.clean(now, subkey, key, deleteMapWhenEmpty)
-	//}
+	if !t.DisableChecksOnRetrieves {
+		t.clean(now, subkey, key, deleteMapWhenEmpty)
+	}
 	if v, h := t.Events[subkey]; h {
 		if v, h := v[key]; h {
-			return len(v)
+			return v.Len()
+		}
+	}
+	return 0
+}
+
+func (t *TimedMultiEventsStoreL3[KL3, KL2, EventT]) countWindow(now int64, subkey KL3, key KL2, window time.Duration, deleteMapWhenEmpty bool) (count int) {
+	if !t.DisableChecksOnRetrieves {
+		t.clean(now, subkey, key, deleteMapWhenEmpty)
+	}
+	if v, h := t.Events[subkey]; h {
+		if v, h := v[key]; h {
+			return v.CountSince(now - window.Nanoseconds())
 		}
 	}
 	return 0
@@ -37,9 +49,9 @@ func (t *TimedMultiEventsStoreL3[KL3, KL2, EventT]) count(now int64, subkey KL3,
 
 // has returns whether the keys exist in the store. Doesn't check for a 2lvl map length != 0. In you need to, you need count() != 0
 func (t *TimedMultiEventsStoreL3[KL3, KL2, EventT]) has(now int64, subkey KL3, key KL2, deleteMapWhenEmpty bool) (has bool) {
-	//if !t.DisableChecksOnRetrieves {
-	//	t.clean(now, subkey, key, deleteMapWhenEmpty)
-	//}
+	if !t.DisableChecksOnRetrieves {
+		t.clean(now, subkey, key, deleteMapWhenEmpty)
+	}
 	if v, h := t.Events[subkey]; h {
 		_, h := v[key]
 		return h
@@ -49,55 +61,41 @@ func (t *TimedMultiEventsStoreL3[KL3, KL2, EventT]) has(now int64, subkey KL3, k
 }
 
 func (t *TimedMultiEventsStoreL3[KL3, KL2, EventT]) add(now int64, subkey KL3, key KL2, event EventT) {
-	//if !t.DisableChecksOnRetrieves {
-	//	t.clean(now, subkey, key, false)
-	//}
+	if !t.DisableChecksOnInserts {
+		t.clean(now, subkey, key, false)
+	}
 	vl3, h := t.Events[subkey]
 	if !h {
-		vl3 = map[KL2]map[int64]EventWrapper[EventT]{}
+		vl3 = map[KL2]*EventsRing[EventT]{}
 		t.Events[subkey] = vl3
 	}
 	vl2, h := vl3[key]
 	if !h {
-		vl2 = map[int64]EventWrapper[EventT]{}
+		vl2 = new(EventsRing[EventT])
 		vl3[key] = vl2
 	}
-	vl2[now+t.lifetime] = EventWrapper[EventT]{EventTime: now, Event: event}
+	vl2.Push(EventWrapper[EventT]{EventTime: now, Event: event})
 }
 
-// clean cleans only l1 map (and deletes l2 (key) map from l3 (subkey) map if l1 (time) map is empty and deleteMap is set to true)
+// clean cleans only l1 events (and deletes l2 (key) map from l3 (subkey) map if there are no l1 events and deleteMap is set to true)
 // calling clean with non-existing subkey and key is safe
 func (t *TimedMultiEventsStoreL3[KL3, KL2, EventT]) clean(now int64, subkey KL3, key KL2, deleteMap bool) {
 	if mL3, h := t.Events[subkey]; h {
-		if mL2, h := mL3[key]; h {
-			var valid bool
-			for expiration := range mL2 {
-				if now > expiration {
-					delete(mL2, now)
-				} else if !valid {
-					valid = true
-				}
-			}
-			if deleteMap && !valid {
+		if events, h := mL3[key]; h {
+			events.PopBefore(now - t.lifetime)
+			if deleteMap && events.Len() == 0 {
 				delete(mL3, key)
 			}
 		}
 	}
 }
 
-// cleanL3 cleans l2, l1 maps of the l3 subkey. Subkey must exist in the l3 map.
+// cleanL3 cleans l2 maps and l1 events of the l3 subkey. Subkey must exist in the l3 map.
 func (t *TimedMultiEventsStoreL3[KL3, KL2, EventT]) cleanL3(now int64, subkey KL3, deleteMap bool) {
 	var mL3 = t.Events[subkey]
-	for key, mL2 := range mL3 {
-		var valid bool
-		for expiration := range mL2 {
-			if now > expiration {
-				delete(mL2, now)
-			} else if !valid {
-				valid = true
-			}
-		}
-		if deleteMap && !valid {
+	for key, events := range mL3 {
+		events.PopBefore(now - t.lifetime)
+		if deleteMap && events.Len() == 0 {
 			delete(mL3, key)
 		}
 	}
@@ -115,7 +113,7 @@ loop:
 		case <-ctx.Done():
 			break loop
 		default:
-			var now = now.UnixMilli()
+			var now = now.UnixNano()
 			t.s.Lock()
 			for k := range t.Events {
 				t.cleanL3(now, k, deleteMapWhenEmpty)
@@ -138,6 +136,18 @@ func (t *TimedMultiEventsStoreL3[KL3, KL2, EventT]) Count(externalLock bool, sub
 	return t.count(time.Now().UnixNano(), subKey, key, deleteMapWhenEmpty)
 }
 
+// CountWindow returns the amount of the keys' events added during the last
+// window, which should not exceed the lifetime.
+// deleteMapWhenEmpty specifies whether the sub-map should be deleted when it's empty.
+func (t *TimedMultiEventsStoreL3[KL3, KL2, EventT]) CountWindow(externalLock bool, subKey KL3, key KL2, window time.Duration, deleteMapWhenEmpty bool) (count int) {
+	if !externalLock {
+		t.s.Lock()
+		defer t.s.Unlock()
+	}
+
+	return t.countWindow(time.Now().UnixNano(), subKey, key, window, deleteMapWhenEmpty)
+}
+
 // Has returns whether the key exists in the store regardless of its content length.
 // deleteMapWhenEmpty specifies whether the sub-map should be deleted when it's empty.
 func (t *TimedMultiEventsStoreL3[KL3, KL2, EventT]) Has(externalLock bool, subKey KL3, key KL2, deleteMapWhenEmpty bool) (has bool) {